@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// compose Writes the composition of the mappings of a build that was
+// obfuscated twice, from original names to final names.
+func compose(args []string) {
+	if len(args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	first := readMapping(args[0])
+	second := readMapping(args[1])
+
+	writer := retrace.NewMappingWriter(os.Stdout)
+	retrace.ComposeMappings(first, second, writer)
+	if err := writer.Flush(); err != nil {
+		fmt.Printf("Error writing mapping: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// convert Converts a mapping file from one format to another.
+func convert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	formatNames := strings.Join(retrace.MappingFormatNames(), ", ")
+	from := flags.String("from", "proguard", "the format of the mapping file: "+formatNames)
+	to := flags.String("to", "tiny2", "the format to write: "+formatNames)
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	for _, format := range []string{*from, *to} {
+		if _, ok := retrace.MappingFormats[format]; !ok {
+			fmt.Printf("Unknown mapping format %s, expected one of: %s\n", format, formatNames)
+			os.Exit(1)
+		}
+	}
+
+	reader := openFile(flags.Arg(0), "Mapping file")
+	defer reader.Close()
+
+	losses, err := retrace.ConvertMapping(*from, *to, reader, os.Stdout)
+	if err != nil {
+		fmt.Printf("Error converting mapping file %s: %s\n", flags.Arg(0), err)
+		os.Exit(1)
+	}
+
+	for _, loss := range losses.Read {
+		fmt.Fprintf(os.Stderr, "Warning: reading %s leaves out %s\n", *from, loss)
+	}
+	for _, loss := range losses.Written {
+		fmt.Fprintf(os.Stderr, "Warning: %s can't express %s\n", *to, loss)
+	}
+}
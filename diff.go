@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// diff Compares the mappings of two releases.
+func diff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print the differences as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	mappingDiff := retrace.DiffMappings(
+		readInverseMapping(flags.Arg(0)),
+		readInverseMapping(flags.Arg(1)))
+
+	var err error
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(mappingDiff)
+	} else {
+		err = mappingDiff.WriteSummary(os.Stdout)
+	}
+
+	if err != nil {
+		fmt.Printf("Error writing differences: %s\n", err)
+		os.Exit(1)
+	}
+}
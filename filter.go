@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// filter Writes the part of a mapping file with the given packages and
+// classes, and optionally with the other names that some traces reference.
+func filter(args []string) {
+	flags := flag.NewFlagSet("filter", flag.ExitOnError)
+	keep := flags.String("keep", "", "comma-separated original package and class names to keep")
+	traces := flags.String("traces", "", "comma-separated trace files whose obfuscated names to keep as well")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || len(*keep) == 0 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	var classNames []string
+	for _, className := range strings.Split(*keep, ",") {
+		if className = strings.TrimSpace(className); len(className) > 0 {
+			classNames = append(classNames, className)
+		}
+	}
+
+	referencedNames := make(map[string]bool)
+	if len(*traces) > 0 {
+		for _, traceFilePath := range strings.Split(*traces, ",") {
+			reader := openFile(traceFilePath, "Trace file")
+			names, err := retrace.CollectTraceNames(reader)
+			reader.Close()
+			if err != nil {
+				fmt.Printf("Error reading trace file %s: %s\n", traceFilePath, err)
+				os.Exit(1)
+			}
+			for name := range names {
+				referencedNames[name] = true
+			}
+		}
+	}
+
+	writer := retrace.NewMappingWriter(os.Stdout)
+	pumpMapping(flags.Arg(0), retrace.NewMappingFilter(writer, classNames, referencedNames))
+
+	if err := writer.Flush(); err != nil {
+		fmt.Printf("Error writing mapping: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// mappingCandidate A mapping that may fit a stack trace.
+type mappingCandidate struct {
+	name string
+	path string
+	// The app and flavor of a mapping in the store.
+	track string
+}
+
+// fit Prints how well candidate mappings fit a stack trace, best first. The
+// candidates are the given mapping files, or the mappings in the store that
+// the store flags select.
+func fit(args []string) {
+	flags := flag.NewFlagSet("fit", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	jsonOutput := flags.Bool("json", false, "print the fits as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || (flags.NArg() < 2 && !selection.selected()) {
+		printUsage()
+		os.Exit(1)
+	}
+
+	frames := readTraceFrames(flags.Arg(0))
+	fits, _ := fitMappings(frames, mappingCandidates(selection, flags.Args()[1:]))
+
+	if *jsonOutput {
+		type scoredFit struct {
+			retrace.MappingFit
+			Score float64 `json:"score"`
+		}
+		scoredFits := make([]scoredFit, len(fits))
+		for index := range fits {
+			scoredFits[index] = scoredFit{fits[index], fits[index].Score()}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(scoredFits); err != nil {
+			fmt.Printf("Error writing fits: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("%d method frames, %d with line numbers\n", len(frames), countLineFrames(frames))
+	for _, fit := range fits {
+		fmt.Printf("%5.1f%%  classes %d  methods %d  lines %d  ambiguous %d  %s\n",
+			100*fit.Score(), fit.Classes, fit.Methods, fit.Lines, fit.Ambiguous, fit.Name)
+	}
+}
+
+// mappingCandidates returns the given mapping files, or the mappings in the
+// store that the store flags select, or exits if there are none.
+func mappingCandidates(selection *storeFlags, mappingFilePaths []string) []mappingCandidate {
+	var candidates []mappingCandidate
+	if !selection.selected() {
+		for _, mappingFilePath := range mappingFilePaths {
+			candidates = append(candidates, mappingCandidate{mappingFilePath, mappingFilePath, ""})
+		}
+		return candidates
+	}
+
+	store := selection.open()
+	entries, err := store.List(selection.query())
+	if err != nil {
+		fmt.Printf("Error reading mapping store %s: %s\n", *selection.root, err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No mapping found in %s for %s\n", *selection.root, describeQuery(selection.query()))
+		os.Exit(1)
+	}
+
+	for index := range entries {
+		entry := &entries[index]
+		track := entry.AppID
+		if len(entry.Flavor) > 0 {
+			track += " " + entry.Flavor
+		}
+		name := entry.AppID + " " + entry.Version()
+		if len(entry.Flavor) > 0 {
+			name += " " + entry.Flavor
+		}
+		// Entries of the same version may differ by map ID.
+		if len(entry.MapID) > 0 {
+			name += " " + entry.MapID
+		}
+		candidates = append(candidates, mappingCandidate{name, store.ObjectPath(entry), track})
+	}
+
+	return candidates
+}
+
+// fitMappings Reads the given candidate mappings one at a time, and returns
+// how well they fit the given frames, with the candidates in the same order,
+// best first.
+func fitMappings(frames []retrace.FrameInfo, candidates []mappingCandidate) ([]retrace.MappingFit, []mappingCandidate) {
+	fits := make([]retrace.MappingFit, 0, len(candidates))
+	for _, candidate := range candidates {
+		// The candidates may be mapping indices as well.
+		if mappingIndex := readMappingIndex([]string{candidate.path}); mappingIndex != nil {
+			fits = append(fits, retrace.FitMapping(candidate.name, mappingIndex, frames))
+			mappingIndex.Close()
+		} else {
+			fits = append(fits, retrace.FitMapping(candidate.name, readMapping(candidate.path), frames))
+		}
+	}
+
+	rankedCandidates := make([]mappingCandidate, len(fits))
+	for rank, index := range retrace.RankMappingFits(fits) {
+		rankedCandidates[rank] = candidates[index]
+	}
+
+	return fits, rankedCandidates
+}
+
+// bestMapping returns the path of the candidate mapping that fits the given
+// frames best, and reports the choice on the standard error.
+func bestMapping(frames []retrace.FrameInfo, candidates []mappingCandidate) string {
+	if len(candidates) == 1 {
+		return candidates[0].path
+	}
+
+	fits, rankedCandidates := fitMappings(frames, candidates)
+	best := fits[0]
+	fmt.Fprintf(os.Stderr, "Using %s, which fits %.1f%% of the frames\n", best.Name, 100*best.Score())
+	if len(fits) > 1 && !best.BetterThan(&fits[1]) {
+		fmt.Fprintf(os.Stderr, "Warning: %s fits equally well\n", fits[1].Name)
+	}
+
+	return rankedCandidates[0].path
+}
+
+// readTraceFrames Reads the method frames of the given crash log file, or
+// exits if it can't.
+func readTraceFrames(crashLogFilePath string) []retrace.FrameInfo {
+	reader := openFile(crashLogFilePath, "Crash log file")
+	defer reader.Close()
+
+	frames, err := retrace.CollectTraceFrames(reader)
+	if err != nil {
+		fmt.Printf("Error reading crash log file %s: %s\n", crashLogFilePath, err)
+		os.Exit(1)
+	}
+
+	return frames
+}
+
+func countLineFrames(frames []retrace.FrameInfo) int {
+	count := 0
+	for _, frame := range frames {
+		if frame.LineNumber > 0 {
+			count++
+		}
+	}
+
+	return count
+}
@@ -2,10 +2,7 @@ module github.com/swind/go-retrace
 
 go 1.19
 
-require (
-	github.com/emirpasic/gods v1.18.1
-	github.com/stretchr/testify v1.8.4
-)
+require github.com/stretchr/testify v1.8.4
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
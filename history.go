@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// history Prints what an obfuscated name meant in each release, or with
+// -reverse, what an original name was obfuscated to in each release. The
+// releases are the given mapping files, oldest first, or the mappings in the
+// store that the store flags select.
+func history(args []string) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	jsonOutput := flags.Bool("json", false, "print the history as JSON")
+	reverse := flags.Bool("reverse", false, "look up an original name instead of an obfuscated name")
+	all := flags.Bool("all", false, "print every release, rather than ranges of releases with the same symbols")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || (flags.NArg() < 2 && !selection.selected()) {
+		printUsage()
+		os.Exit(1)
+	}
+
+	query := flags.Arg(0)
+	candidates := mappingCandidates(selection, flags.Args()[1:])
+	if selection.selected() {
+		oldestReleasesFirst(candidates)
+	}
+
+	// Read the mappings one at a time, and only the classes that the query
+	// may refer to.
+	name, _, _ := retrace.ParseSymbolQuery(query)
+	releases := make([]retrace.SymbolRelease, 0, len(candidates))
+	for _, candidate := range candidates {
+		var symbols []retrace.Symbol
+		if *reverse {
+			inverse := retrace.NewInverseFrameRemapper()
+			pumpMapping(candidate.path, retrace.NewMappingFilter(inverse, originalClassNames(name), nil))
+			symbols = retrace.LookupOriginalSymbol(inverse, query)
+		} else {
+			remapper := retrace.NewFrameRemapper()
+			pumpMapping(candidate.path, retrace.NewMappingFilter(remapper, nil, obfuscatedNames(name)))
+			symbols = retrace.LookupObfuscatedSymbol(remapper, query)
+		}
+
+		releases = append(releases, retrace.SymbolRelease{Release: candidate.name, Track: candidate.track, Symbols: symbols})
+	}
+
+	if *jsonOutput {
+		var value interface{} = retrace.SummarizeSymbolHistory(releases)
+		if *all {
+			value = releases
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(value); err != nil {
+			fmt.Printf("Error writing history: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *all {
+		for _, release := range releases {
+			fmt.Printf("%s:\n", release.Release)
+			printHistorySymbols(release.Symbols)
+		}
+		return
+	}
+
+	for _, historyRange := range retrace.SummarizeSymbolHistory(releases) {
+		if historyRange.Releases == 1 {
+			fmt.Printf("%s:\n", historyRange.FirstRelease)
+		} else {
+			fmt.Printf("%s .. %s (%d releases):\n", historyRange.FirstRelease, historyRange.LastRelease, historyRange.Releases)
+		}
+		printHistorySymbols(historyRange.Symbols)
+	}
+}
+
+// oldestReleasesFirst Reorders the given mappings of the store, which lists
+// them by app and flavor, latest version first, to list the releases of each
+// app and flavor oldest first.
+func oldestReleasesFirst(candidates []mappingCandidate) {
+	start := 0
+	for end := 1; end <= len(candidates); end++ {
+		if end < len(candidates) && candidates[end].track == candidates[start].track {
+			continue
+		}
+
+		for index1, index2 := start, end-1; index1 < index2; index1, index2 = index1+1, index2-1 {
+			candidates[index1], candidates[index2] = candidates[index2], candidates[index1]
+		}
+		start = end
+	}
+}
+
+func printHistorySymbols(symbols []retrace.Symbol) {
+	if len(symbols) == 0 {
+		fmt.Println("    no mapping found")
+	}
+	for _, symbol := range symbols {
+		fmt.Printf("    %s\n", symbol)
+	}
+}
+
+// obfuscatedNames returns the obfuscated class name and class member name
+// that the given obfuscated name may refer to.
+func obfuscatedNames(name string) map[string]bool {
+	names := map[string]bool{name: true}
+	if dotIndex := strings.LastIndex(name, "."); dotIndex >= 0 {
+		names[name[:dotIndex]] = true
+	}
+
+	return names
+}
+
+// originalClassNames returns the original class names that the given
+// original name may refer to.
+func originalClassNames(name string) []string {
+	classNames := []string{name}
+	if dotIndex := strings.LastIndex(name, "."); dotIndex >= 0 {
+		classNames = append(classNames, name[:dotIndex])
+	}
+
+	return classNames
+}
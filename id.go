@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// id Prints the identity of a mapping file, and exits with 1 if its
+// pg_map_hash doesn't match its content, or if it doesn't have the expected
+// map ID or debug ID.
+func id(args []string) {
+	flags := flag.NewFlagSet("id", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print the identity as JSON")
+	check := flags.String("check", "", "the pg_map_id or debug ID that the mapping file should have, like the one of a crash report")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	mappingFilePath := flags.Arg(0)
+	reader := openFile(mappingFilePath, "Mapping file")
+	defer reader.Close()
+
+	identity, err := retrace.ComputeMappingIdentity(reader)
+	if err != nil {
+		fmt.Printf("Error reading mapping file %s: %s\n", mappingFilePath, err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(identity); err != nil {
+			fmt.Printf("Error writing identity: %s\n", err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Printf("pg_map_id:   %s\n", identity.MapID)
+		fmt.Printf("pg_map_hash: %s\n", identity.MapHash)
+		fmt.Printf("computed:    %s\n", identity.ComputedHash)
+		fmt.Printf("debug ID:    %s\n", identity.DebugID)
+	}
+
+	if err := identity.Verify(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", mappingFilePath, err)
+		os.Exit(1)
+	}
+
+	if len(*check) > 0 &&
+		!strings.EqualFold(*check, identity.MapID) &&
+		!strings.EqualFold(*check, identity.DebugID) {
+		fmt.Fprintf(os.Stderr, "%s: has pg_map_id %s and debug ID %s, not %s\n", mappingFilePath, identity.MapID, identity.DebugID, *check)
+		os.Exit(1)
+	}
+}
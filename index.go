@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// index Writes a binary index of a mapping file, to retrace with instead of
+// the mapping file.
+func index(args []string) {
+	if len(args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	mappingFilePath := args[0]
+	indexFilePath := args[1]
+
+	indexFile, err := os.Create(indexFilePath)
+	if err != nil {
+		fmt.Printf("Error creating index file: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = retrace.WriteMappingIndex(readMapping(mappingFilePath), indexFile)
+	if closeErr := indexFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("Error writing index file %s: %s\n", indexFilePath, err)
+		os.Exit(1)
+	}
+}
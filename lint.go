@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// lint Reports the problems in a mapping file, and exits with 1 if any of
+// them are errors.
+func lint(args []string) {
+	if len(args) < 1 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	mappingFilePath := args[0]
+	reader := openFile(mappingFilePath, "Mapping file")
+	defer reader.Close()
+
+	findings, err := retrace.LintMapping(reader)
+	if err != nil {
+		fmt.Printf("Error reading mapping file %s: %s\n", mappingFilePath, err)
+		os.Exit(1)
+	}
+
+	errorCount := 0
+	for _, finding := range findings {
+		fmt.Printf("%s:%s\n", mappingFilePath, finding)
+		if finding.Severity == retrace.LintError {
+			errorCount++
+		}
+	}
+
+	if errorCount > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// lookup Prints the original candidates of obfuscated names, or the
+// obfuscated candidates of original names.
+func lookup(args []string) {
+	flags := flag.NewFlagSet("lookup", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print the candidates as JSON")
+	reverse := flags.Bool("reverse", false, "look up original names instead of obfuscated names")
+	interactive := flags.Bool("i", false, "read the names to look up from the standard input, one per line")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || (flags.NArg() < 2 && !*interactive) {
+		printUsage()
+		os.Exit(1)
+	}
+
+	var lookupSymbol func(query string) []retrace.Symbol
+	if *reverse {
+		inverse := readInverseMapping(flags.Arg(0))
+		lookupSymbol = func(query string) []retrace.Symbol {
+			return retrace.LookupOriginalSymbol(inverse, query)
+		}
+	} else {
+		remapper := readMapping(flags.Arg(0))
+		lookupSymbol = func(query string) []retrace.Symbol {
+			return retrace.LookupObfuscatedSymbol(remapper, query)
+		}
+	}
+
+	printSymbols := func(query string) {
+		symbols := lookupSymbol(query)
+		if *jsonOutput {
+			if symbols == nil {
+				symbols = []retrace.Symbol{}
+			}
+			if err := json.NewEncoder(os.Stdout).Encode(symbols); err != nil {
+				fmt.Printf("Error writing candidates: %s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if len(symbols) == 0 {
+			fmt.Printf("%s: no mapping found\n", query)
+			return
+		}
+		for _, symbol := range symbols {
+			fmt.Println(symbol)
+		}
+	}
+
+	for _, query := range flags.Args()[1:] {
+		printSymbols(query)
+	}
+
+	if *interactive {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if query := strings.TrimSpace(scanner.Text()); len(query) > 0 {
+				printSymbols(query)
+			}
+		}
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"runtime"
 	"strings"
 
 	"github.com/swind/go-retrace/retrace"
@@ -15,68 +18,289 @@ import (
 func main() {
 	args := os.Args[1:]
 
-	if len(args) < 2 {
-		fmt.Printf("Usage: %s <mapping file> <crash log file>\n", os.Args[0])
+	if len(args) > 0 {
+		switch args[0] {
+		case "compose":
+			compose(args[1:])
+			return
+		case "convert":
+			convert(args[1:])
+			return
+		case "diff":
+			diff(args[1:])
+			return
+		case "filter":
+			filter(args[1:])
+			return
+		case "fit":
+			fit(args[1:])
+			return
+		case "history":
+			history(args[1:])
+			return
+		case "id":
+			id(args[1:])
+			return
+		case "index":
+			index(args[1:])
+			return
+		case "lint":
+			lint(args[1:])
+			return
+		case "lookup":
+			lookup(args[1:])
+			return
+		case "obfuscate":
+			obfuscate(args[1:])
+			return
+		case "smali":
+			smali(args[1:])
+			return
+		case "store":
+			store(args[1:])
+			return
+		case "stats", "audit":
+			stats(args[1:])
+			return
+		}
+	}
+
+	retraceCrashLog(args)
+}
+
+func printUsage() {
+	fmt.Printf("Usage: %s [-synthetic keep|hide|fold] [-kotlin] [-coroutines] [-source-files <sources>] [-json] <mapping file> [<mapping file>...] <crash log file>\n", os.Args[0])
+	fmt.Printf("       %s <index file> <crash log file>\n", os.Args[0])
+	fmt.Printf("       %s -app <app id> [-version <version>] [-flavor <flavor>] [-map-id <map id>] <crash log file>\n", os.Args[0])
+	fmt.Printf("       %s -auto [-app <app id>] [<mapping file>...] <crash log file>\n", os.Args[0])
+	fmt.Printf("       %s compose <first mapping file> <second mapping file>\n", os.Args[0])
+	fmt.Printf("       %s convert [-from <format>] [-to <format>] <mapping file>\n", os.Args[0])
+	fmt.Printf("       %s diff [-json] <old mapping file> <new mapping file>\n", os.Args[0])
+	fmt.Printf("       %s filter -keep <names> [-traces <trace files>] <mapping file>\n", os.Args[0])
+	fmt.Printf("       %s fit [-json] [-app <app id>] <crash log file> [<mapping file>...]\n", os.Args[0])
+	fmt.Printf("       %s history [-json] [-reverse] [-all] [-app <app id>] <name> [<mapping file>...]\n", os.Args[0])
+	fmt.Printf("       %s id [-json] [-check <map id>] <mapping file>\n", os.Args[0])
+	fmt.Printf("       %s index <mapping file> <index file>\n", os.Args[0])
+	fmt.Printf("       %s lint <mapping file>\n", os.Args[0])
+	fmt.Printf("       %s lookup [-json] [-reverse] [-i] <mapping file> [<name>...]\n", os.Args[0])
+	fmt.Printf("       %s obfuscate [-check] [-source-file <name>] <mapping file> <trace file>\n", os.Args[0])
+	fmt.Printf("       %s smali [-app <app id>] <mapping file> <smali file or directory> [<output directory>]\n", os.Args[0])
+	fmt.Printf("       %s stats [-json] <mapping file>\n", os.Args[0])
+	fmt.Printf("       %s store add -app <app id> [-version <version>] [-version-code <code>] [-flavor <flavor>] <mapping file>\n", os.Args[0])
+	fmt.Printf("       %s store list|get|delete [-app <app id>] [-version <version>] [-flavor <flavor>] [-map-id <map id>]\n", os.Args[0])
+	fmt.Printf("       %s store list -json\n", os.Args[0])
+	fmt.Printf("       %s store prune [-app <app>] [-flavor <flavor>] [-keep <count>] [-max-age <age>]\n", os.Args[0])
+}
+
+func retraceCrashLog(args []string) {
+	flags := flag.NewFlagSet("retrace", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	auto := flags.Bool("auto", false, "use the mapping that fits the crash log best, of the given mapping files or the mappings in the store")
+	synthetic := flags.String("synthetic", "keep", "how to print frames of synthesized code, like lambda classes, accessors and bridges: keep, hide or fold")
+	kotlin := flags.Bool("kotlin", false, "print the methods that Kotlin generates as Kotlin names, like \"Foo.bar (suspend lambda)\"")
+	coroutines := flags.Bool("coroutines", false, "leave out the frames that resume suspend functions and dispatch coroutines, and the frames duplicated across coroutine boundaries")
+	sourceFiles := flags.String("source-files", "metadata,frame,kotlin", "where to look for the source files of retraced frames, in order, before deriving them from the class names: metadata, frame and kotlin, or java to only derive them")
+	jsonOutput := flags.Bool("json", false, "print the retraced lines with their original frames as JSON")
+	flags.Parse(args)
+	args = flags.Args()
+
+	syntheticFrames, err := retrace.ParseSyntheticFrameMode(*synthetic)
+	if err != nil {
+		fmt.Printf("%s\n", err)
 		os.Exit(1)
 	}
 
-	// The first argument is the mapping file
-	mappingFilePath := args[0]
+	sourceFileStrategy, err := retrace.ParseSourceFileStrategy(*sourceFiles)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
 
-	// Check mappingFile exists
-	if _, err := os.Stat(mappingFilePath); os.IsNotExist(err) {
-		fmt.Printf("Mapping file %s does not exist\n", mappingFilePath)
+	if len(args) < 1 || (len(args) < 2 && !selection.selected()) {
+		printUsage()
 		os.Exit(1)
 	}
 
-	// The second argument is the crash log file
-	crashLogFilePath := args[1]
-	// Check crashLogFile exists
-	if _, err := os.Stat(crashLogFilePath); os.IsNotExist(err) {
-		fmt.Printf("Crash log file %s does not exist\n", crashLogFilePath)
+	// The mapping is either in the store, or in the given files.
+	var mappingFilePaths []string
+	if *auto {
+		frames := readTraceFrames(args[len(args)-1])
+		candidates := mappingCandidates(selection, args[:len(args)-1])
+		mappingFilePaths = []string{bestMapping(frames, candidates)}
+	} else if selection.selected() {
+		mappingFilePaths = []string{selection.mappingFilePath()}
+	} else {
+		// The leading arguments are the mapping files, in order of
+		// precedence, or a single mapping index
+		mappingFilePaths = args[:len(args)-1]
+	}
+
+	var remapper retrace.Remapper
+	if mappingIndex := readMappingIndex(mappingFilePaths); mappingIndex != nil {
+		remapper = mappingIndex
+	} else {
+		remapper = mergeMappings(mappingFilePaths)
+	}
+
+	retrace := retrace.NewRetraceWithRemapper(remapper)
+	retrace.SyntheticFrames = syntheticFrames
+	retrace.KotlinNames = *kotlin
+	retrace.CoroutineFrames = *coroutines
+	retrace.SourceFiles = sourceFileStrategy
+
+	// The last argument is the crash log file
+	crashLogFileReader := openFile(args[len(args)-1], "Crash log file")
+	defer crashLogFileReader.Close()
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(retrace.RetraceLines(crashLogFileReader)); err != nil {
+			fmt.Printf("Error writing retraced lines: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	resultBuffer := bytes.NewBufferString("")
+	retrace.Retrace(crashLogFileReader, resultBuffer)
+
+	fmt.Printf("%s", resultBuffer.String())
+}
+
+// readMappingIndex Reads the given file if it is a single mapping index, or
+// returns nil if it is a mapping file.
+func readMappingIndex(mappingFilePaths []string) *retrace.MappingIndex {
+	// Check the start of the file before reading all of it.
+	reader := openFile(mappingFilePaths[0], "Mapping file")
+	defer reader.Close()
+	data := make([]byte, 4)
+	count, _ := io.ReadFull(reader, data)
+	if !retrace.IsMappingIndex(data[:count]) {
+		return nil
+	}
+
+	if len(mappingFilePaths) > 1 {
+		fmt.Printf("Mapping index %s can't be merged with other mapping files\n", mappingFilePaths[0])
 		os.Exit(1)
 	}
 
-	// Read the mapping file
-	mappingFile, err := os.Open(mappingFilePath)
+	var mappingIndex *retrace.MappingIndex
+	var err error
+	if strings.HasSuffix(mappingFilePaths[0], ".gz") {
+		var rest []byte
+		if rest, err = io.ReadAll(reader); err == nil {
+			mappingIndex, err = retrace.NewMappingIndex(append(data, rest...))
+		}
+	} else {
+		// Map the index into memory rather than reading all of it.
+		mappingIndex, err = retrace.OpenMappingIndex(mappingFilePaths[0])
+	}
 	if err != nil {
-		fmt.Printf("Error opening mapping file: %s\n", err)
+		fmt.Printf("Error reading mapping index %s: %s\n", mappingFilePaths[0], err)
 		os.Exit(1)
 	}
 
-	var mappingFileReader io.Reader
-	if strings.HasSuffix(mappingFilePath, ".gz") {
-		mappingFileReader, err = gzip.NewReader(mappingFile)
+	return mappingIndex
+}
+
+// mergeMappings Reads the given mapping files, in order of precedence, and
+// warns about the obfuscated class names that they claim for different
+// classes.
+func mergeMappings(mappingFilePaths []string) *retrace.FrameRemapper {
+	remapper := retrace.NewFrameRemapper()
+	merger := retrace.NewMappingMerger(remapper)
+	merger.Workers = runtime.NumCPU()
+	for _, mappingFilePath := range mappingFilePaths {
+		reader := openFile(mappingFilePath, "Mapping file")
+		err := merger.Merge(mappingFilePath, reader)
+		reader.Close()
 		if err != nil {
-			fmt.Printf("Error opening mapping file: %s\n", err)
+			fmt.Printf("Error reading mapping file %s: %s\n", mappingFilePath, err)
 			os.Exit(1)
 		}
-	} else {
-		mappingFileReader = bufio.NewReader(mappingFile)
 	}
 
-	retrace := retrace.NewRetrace(mappingFileReader)
+	for _, conflict := range merger.Conflicts {
+		if conflict.Merged {
+			fmt.Fprintf(os.Stderr, "Note: %s is %s in %s and %s in %s, using both\n",
+				conflict.ClassName,
+				conflict.ObfuscatedClassName, conflict.MappingName,
+				conflict.IgnoredObfuscatedClassName, conflict.IgnoredMappingName)
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: %s is %s in %s and %s in %s, using %s\n",
+			conflict.ObfuscatedClassName,
+			conflict.ClassName, conflict.MappingName,
+			conflict.IgnoredClassName, conflict.IgnoredMappingName,
+			conflict.MappingName)
+	}
+
+	return remapper
+}
+
+// fileReader Reads an open file, and closes it.
+type fileReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (reader *fileReader) Close() error {
+	return reader.file.Close()
+}
 
-	// Read the crash log file
-	crashLogFile, err := os.Open(crashLogFilePath)
+// openFile Opens the given file, which may be gzipped, or exits if it can't.
+// The caller closes the file.
+func openFile(filePath string, description string) io.ReadCloser {
+	// Check the file exists
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		fmt.Printf("%s %s does not exist\n", description, filePath)
+		os.Exit(1)
+	}
+
+	file, err := os.Open(filePath)
 	if err != nil {
-		fmt.Printf("Error opening crash log file: %s\n", err)
+		fmt.Printf("Error opening %s: %s\n", strings.ToLower(description), err)
 		os.Exit(1)
 	}
 
-	var crashLogFileReader io.Reader
-	if strings.HasSuffix(crashLogFilePath, ".gz") {
-		crashLogFileReader, err = gzip.NewReader(crashLogFile)
+	if strings.HasSuffix(filePath, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
 		if err != nil {
-			fmt.Printf("Error opening crash log file: %s\n", err)
+			fmt.Printf("Error opening %s: %s\n", strings.ToLower(description), err)
 			os.Exit(1)
 		}
-	} else {
-		crashLogFileReader = bufio.NewReader(crashLogFile)
+		return &fileReader{gzipReader, file}
 	}
 
-	resultBuffer := bytes.NewBufferString("")
-	retrace.Retrace(crashLogFileReader, resultBuffer)
+	return &fileReader{bufio.NewReader(file), file}
+}
 
-	fmt.Printf("%s", resultBuffer.String())
+// readMapping Reads the given mapping file, or exits if it can't.
+func readMapping(filePath string) *retrace.FrameRemapper {
+	remapper := retrace.NewFrameRemapper()
+	pumpMapping(filePath, remapper)
+	return remapper
+}
+
+// readInverseMapping Reads the given mapping file indexed by original names,
+// or exits if it can't.
+func readInverseMapping(filePath string) *retrace.InverseFrameRemapper {
+	inverse := retrace.NewInverseFrameRemapper()
+	pumpMapping(filePath, inverse)
+	return inverse
+}
+
+// pumpMapping Reads the given mapping file into the given processor, or exits
+// if it can't.
+func pumpMapping(filePath string, processor retrace.MappingProcessor) {
+	file := openFile(filePath, "Mapping file")
+	defer file.Close()
+
+	reader := retrace.NewParallelMappingReader(file, runtime.NumCPU())
+	if err := reader.Pump(processor); err != nil {
+		fmt.Printf("Error reading mapping file %s: %s\n", filePath, err)
+		os.Exit(1)
+	}
 }
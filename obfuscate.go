@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// obfuscate Writes the stack trace that a release build would have printed
+// for a readable stack trace, and with -check, verifies that retracing it
+// results in the original frames again.
+func obfuscate(args []string) {
+	flags := flag.NewFlagSet("obfuscate", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	sourceFile := flags.String("source-file", "SourceFile", "the source file of the obfuscated frames, or empty to keep the original source files")
+	check := flags.Bool("check", false, "retrace the obfuscated trace, and exit with 1 if it doesn't result in the original frames")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || (flags.NArg() < 2 && !selection.selected()) {
+		printUsage()
+		os.Exit(1)
+	}
+
+	var mappingFilePath string
+	if selection.selected() {
+		mappingFilePath = selection.mappingFilePath()
+	} else {
+		mappingFilePath = flags.Arg(0)
+	}
+	traceFilePath := flags.Arg(flags.NArg() - 1)
+
+	obfuscator := retrace.NewTraceObfuscator()
+	obfuscator.SourceFile = *sourceFile
+	pumpMapping(mappingFilePath, obfuscator)
+
+	obfuscated := bytes.NewBufferString("")
+	reader := openFile(traceFilePath, "Trace file")
+	defer reader.Close()
+
+	if err := obfuscator.Obfuscate(reader, obfuscated); err != nil {
+		fmt.Printf("Error reading trace file %s: %s\n", traceFilePath, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s", obfuscated.String())
+
+	if *check {
+		retraced := bytes.NewBufferString("")
+		retrace.NewRetraceWithRemapper(readMapping(mappingFilePath)).Retrace(bytes.NewReader(obfuscated.Bytes()), retraced)
+
+		originalFrames := readTraceFrames(traceFilePath)
+		retracedFrames, _ := retrace.CollectTraceFrames(retraced)
+		if mismatches := compareFrames(originalFrames, retracedFrames); mismatches > 0 {
+			fmt.Fprintf(os.Stderr, "%d frames don't retrace to the original frames\n", mismatches)
+			os.Exit(1)
+		}
+	}
+}
+
+// compareFrames Prints the original frames that the retraced frames don't
+// match, by class, method and line number, and returns their number.
+func compareFrames(originalFrames []retrace.FrameInfo, retracedFrames []retrace.FrameInfo) int {
+	mismatches := 0
+	for index, originalFrame := range originalFrames {
+		if index < len(retracedFrames) &&
+			retracedFrames[index].ClassName == originalFrame.ClassName &&
+			retracedFrames[index].MethodName == originalFrame.MethodName &&
+			retracedFrames[index].LineNumber == originalFrame.LineNumber {
+			continue
+		}
+
+		mismatches++
+		if index < len(retracedFrames) {
+			fmt.Fprintf(os.Stderr, "Frame %d: %s.%s:%d retraces to %s.%s:%d\n", index+1,
+				originalFrame.ClassName, originalFrame.MethodName, originalFrame.LineNumber,
+				retracedFrames[index].ClassName, retracedFrames[index].MethodName, retracedFrames[index].LineNumber)
+		} else {
+			fmt.Fprintf(os.Stderr, "Frame %d: %s.%s:%d is missing\n", index+1,
+				originalFrame.ClassName, originalFrame.MethodName, originalFrame.LineNumber)
+		}
+	}
+
+	return mismatches
+}
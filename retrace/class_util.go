@@ -2,6 +2,32 @@ package retrace
 
 import "strings"
 
+// Reference: https://github.com/Guardsquare/proguard-core/blob/master/base/src/main/java/proguard/classfile/util/ClassUtil.java
+
+var internalPrimitiveTypes = map[string]string{
+	"boolean": "Z",
+	"byte":    "B",
+	"char":    "C",
+	"short":   "S",
+	"int":     "I",
+	"long":    "J",
+	"float":   "F",
+	"double":  "D",
+	"void":    "V",
+}
+
+var externalPrimitiveTypes = map[byte]string{
+	'Z': "boolean",
+	'B': "byte",
+	'C': "char",
+	'S': "short",
+	'I': "int",
+	'J': "long",
+	'F': "float",
+	'D': "double",
+	'V': "void",
+}
+
 /*
 * Convert an internal class name into an external class name.
 * e.g. java/lang/Object -> java.lang.Object
@@ -9,3 +35,224 @@ import "strings"
 func ExternalClassName(name string) string {
 	return strings.ReplaceAll(name, "/", ".")
 }
+
+/*
+* Convert an external class name into an internal class name.
+* e.g. java.lang.Object -> java/lang/Object
+ */
+func InternalClassName(name string) string {
+	return strings.ReplaceAll(name, ".", "/")
+}
+
+/*
+* Convert an external type into an internal type.
+* e.g. int -> I, java.lang.String[] -> [Ljava/lang/String;
+ */
+func InternalType(externalType string) string {
+	var buffer strings.Builder
+	for strings.HasSuffix(externalType, "[]") {
+		buffer.WriteString("[")
+		externalType = externalType[:len(externalType)-2]
+	}
+
+	if internalType, ok := internalPrimitiveTypes[externalType]; ok {
+		buffer.WriteString(internalType)
+	} else {
+		buffer.WriteString("L")
+		buffer.WriteString(InternalClassName(externalType))
+		buffer.WriteString(";")
+	}
+
+	return buffer.String()
+}
+
+/*
+* Convert an internal type into an external type.
+* e.g. I -> int, [Ljava/lang/String; -> java.lang.String[]
+ */
+func ExternalType(internalType string) string {
+	externalType, _ := externalTypeAt(internalType, 0)
+	return externalType
+}
+
+/*
+* Convert external method return type and arguments into an internal method
+* descriptor.
+* e.g. void, int,java.lang.String -> (ILjava/lang/String;)V
+ */
+func InternalMethodDescriptor(returnType string, arguments string) string {
+	var buffer strings.Builder
+	buffer.WriteString("(")
+	if len(strings.TrimSpace(arguments)) > 0 {
+		for _, argument := range strings.Split(arguments, ",") {
+			buffer.WriteString(InternalType(strings.TrimSpace(argument)))
+		}
+	}
+	buffer.WriteString(")")
+	buffer.WriteString(InternalType(returnType))
+
+	return buffer.String()
+}
+
+/*
+* Convert an internal method descriptor into external method arguments.
+* e.g. (ILjava/lang/String;)V -> int,java.lang.String
+ */
+func ExternalMethodArguments(internalMethodDescriptor string) string {
+	arguments, _ := externalMethodType(internalMethodDescriptor)
+	return strings.Join(arguments, ",")
+}
+
+/*
+* Convert an internal method descriptor into an external method return type.
+* e.g. (ILjava/lang/String;)V -> void
+ */
+func ExternalMethodReturnType(internalMethodDescriptor string) string {
+	_, returnType := externalMethodType(internalMethodDescriptor)
+	return returnType
+}
+
+// externalMethodType Returns the external arguments and return type of the
+// given internal method descriptor.
+func externalMethodType(internalMethodDescriptor string) ([]string, string) {
+	if !strings.HasPrefix(internalMethodDescriptor, "(") {
+		return nil, ""
+	}
+
+	var arguments []string
+	index := 1
+	for index < len(internalMethodDescriptor) && internalMethodDescriptor[index] != ')' {
+		var argument string
+		argument, index = externalTypeAt(internalMethodDescriptor, index)
+		arguments = append(arguments, argument)
+	}
+
+	returnType := ""
+	if index < len(internalMethodDescriptor) {
+		returnType, _ = externalTypeAt(internalMethodDescriptor, index+1)
+	}
+
+	return arguments, returnType
+}
+
+// externalTypeAt Returns the external type of the internal type at the given
+// index, and the index after it.
+func externalTypeAt(internalType string, index int) (string, int) {
+	dimensionCount := 0
+	for index < len(internalType) && internalType[index] == '[' {
+		dimensionCount++
+		index++
+	}
+
+	if index >= len(internalType) {
+		return "", index
+	}
+
+	var externalType string
+	if internalType[index] == 'L' {
+		endIndex := IndexOf(internalType, ";", index)
+		if endIndex < 0 {
+			endIndex = len(internalType)
+		}
+		externalType = ExternalClassName(internalType[index+1 : endIndex])
+		index = endIndex + 1
+	} else if primitiveType, ok := externalPrimitiveTypes[internalType[index]]; ok {
+		externalType = primitiveType
+		index++
+	} else {
+		// Not a valid type, so just take the rest.
+		externalType = internalType[index:]
+		index = len(internalType)
+	}
+
+	return externalType + strings.Repeat("[]", dimensionCount), index
+}
+
+/*
+* Check whether the given string is an internal field descriptor.
+* e.g. I, [La/b; or Ljava/lang/String;
+ */
+func IsFieldDescriptor(descriptor string) bool {
+	index, ok := skipInternalType(descriptor, 0)
+	return ok && index == len(descriptor)
+}
+
+/*
+* Check whether the given string is an internal method descriptor.
+* e.g. ()V or (ILjava/lang/String;)[La/b;
+ */
+func IsMethodDescriptor(descriptor string) bool {
+	if !strings.HasPrefix(descriptor, "(") {
+		return false
+	}
+
+	index := 1
+	for index < len(descriptor) && descriptor[index] != ')' {
+		var ok bool
+		if index, ok = skipInternalType(descriptor, index); !ok {
+			return false
+		}
+	}
+
+	if index >= len(descriptor) {
+		return false
+	}
+
+	index, ok := skipInternalType(descriptor, index+1)
+	return ok && index == len(descriptor)
+}
+
+/*
+* Rewrite all class names in an internal field or method descriptor with the
+* given function of external class names, like a Remapper's
+* GetOriginalClassName.
+* e.g. (La/b;[La/c;)La/d; -> (Lcom/example/Foo;[Lcom/example/Bar;)Lcom/example/Baz;
+ */
+func RemapDescriptor(descriptor string, remapClassName func(externalClassName string) string) string {
+	var buffer strings.Builder
+	index := 0
+	for index < len(descriptor) {
+		if descriptor[index] != 'L' {
+			buffer.WriteByte(descriptor[index])
+			index++
+			continue
+		}
+
+		endIndex := IndexOf(descriptor, ";", index)
+		if endIndex < 0 {
+			// Not a valid descriptor, so just take the rest.
+			buffer.WriteString(descriptor[index:])
+			break
+		}
+
+		buffer.WriteString("L")
+		buffer.WriteString(InternalClassName(remapClassName(ExternalClassName(descriptor[index+1 : endIndex]))))
+		buffer.WriteString(";")
+		index = endIndex + 1
+	}
+
+	return buffer.String()
+}
+
+// skipInternalType Returns the index after the internal type at the given
+// index, and whether it is a valid type.
+func skipInternalType(internalType string, index int) (int, bool) {
+	for index < len(internalType) && internalType[index] == '[' {
+		index++
+	}
+
+	if index >= len(internalType) {
+		return index, false
+	}
+
+	if internalType[index] == 'L' {
+		endIndex := IndexOf(internalType, ";", index)
+		if endIndex <= index+1 {
+			return index, false
+		}
+		return endIndex + 1, true
+	}
+
+	_, ok := externalPrimitiveTypes[internalType[index]]
+	return index + 1, ok
+}
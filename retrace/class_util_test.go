@@ -0,0 +1,40 @@
+package retrace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescriptors(t *testing.T) {
+	assert.Equal(t, "(ILjava/lang/String;)V", InternalMethodDescriptor("void", "int,java.lang.String"))
+	assert.Equal(t, "int,java.lang.String", ExternalMethodArguments("(ILjava/lang/String;)V"))
+	assert.Equal(t, "a.b[]", ExternalType("[La/b;"))
+	assert.Equal(t, "[La/b;", InternalType("a.b[]"))
+
+	assert.True(t, IsFieldDescriptor("[La/b;"))
+	assert.True(t, IsFieldDescriptor("J"))
+	assert.False(t, IsFieldDescriptor("La/b"))
+	assert.False(t, IsFieldDescriptor("IZ"))
+	assert.True(t, IsMethodDescriptor("()V"))
+	assert.True(t, IsMethodDescriptor("(I[[JLa/b;)[La/c;"))
+	assert.False(t, IsMethodDescriptor("(I)"))
+	assert.False(t, IsMethodDescriptor("(Q)V"))
+}
+
+func TestRemapDescriptor(t *testing.T) {
+	classNames := map[string]string{
+		"a.b": "com.example.Foo",
+		"a.c": "com.example.Bar",
+	}
+	remap := func(className string) string {
+		if originalClassName, ok := classNames[className]; ok {
+			return originalClassName
+		}
+		return className
+	}
+
+	assert.Equal(t, "(ILcom/example/Foo;[Lcom/example/Bar;)Ljava/lang/String;", RemapDescriptor("(ILa/b;[La/c;)Ljava/lang/String;", remap))
+	assert.Equal(t, "[[Lcom/example/Foo;", RemapDescriptor("[[La/b;", remap))
+	assert.Equal(t, "Z", RemapDescriptor("Z", remap))
+}
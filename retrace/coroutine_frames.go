@@ -0,0 +1,152 @@
+package retrace
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The line that kotlinx.coroutines inserted between the frames of a
+// recovered exception and the frames of the original exception, before
+// version 1.7.
+const coroutineBoundaryLine = "(Coroutine boundary)"
+
+// The class of the artificial frame that kotlinx.coroutines inserts at a
+// coroutine boundary, since version 1.7, like
+// "at _COROUTINE._BOUNDARY._(CoroutineDebugging.kt)".
+const coroutineBoundaryClassName = "_COROUTINE._BOUNDARY"
+
+// The prefixes of the classes of kotlinx.coroutines and of the Kotlin
+// runtime that resume the state machines of suspend functions and dispatch
+// coroutines, rather than run code of the app.
+var coroutineMachineryClassPrefixes = []string{
+	"kotlin.coroutines.jvm.internal.",
+	"kotlin.coroutines.intrinsics.",
+	"kotlinx.coroutines.internal.",
+	"kotlinx.coroutines.intrinsics.",
+	"kotlinx.coroutines.scheduling.",
+	"kotlinx.coroutines.android.HandlerContext",
+	"kotlinx.coroutines.AbstractCoroutine",
+	"kotlinx.coroutines.BlockingCoroutine",
+	"kotlinx.coroutines.BuildersKt",
+	"kotlinx.coroutines.CancellableContinuationImpl",
+	"kotlinx.coroutines.DispatchedContinuation",
+	"kotlinx.coroutines.DispatchedTask",
+	"kotlinx.coroutines.EventLoop",
+	"kotlinx.coroutines.ResumeModeKt",
+}
+
+// IsCoroutineBoundary Returns whether the given line of a stack trace is a
+// boundary that kotlinx.coroutines inserted when it recovered the stack
+// trace of an exception, in either format.
+func IsCoroutineBoundary(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == coroutineBoundaryLine ||
+		strings.HasPrefix(line, "at "+coroutineBoundaryClassName+".")
+}
+
+// IsCoroutineMachineryFrame Returns whether the given original frame is in
+// the code that resumes suspend functions and dispatches coroutines, like
+// "BaseContinuationImpl.resumeWith" or "DispatchedTask.run".
+func IsCoroutineMachineryFrame(frame *FrameInfo) bool {
+	for _, prefix := range coroutineMachineryClassPrefixes {
+		if strings.HasPrefix(frame.ClassName, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// coroutineFrameFilter Leaves out the machinery frames of coroutines, and the
+// frames that the stack trace recovery of kotlinx.coroutines duplicated
+// across coroutine boundaries, so the frames that Retrace prints are the
+// logical chain of suspend calls.
+type coroutineFrameFilter struct {
+	// The original frames of the current stack trace.
+	printedFrames map[FrameInfo]bool
+	// Whether the current stack trace has passed a coroutine boundary.
+	crossedBoundary bool
+	// The number of frames that have been left out since the last printed
+	// frame.
+	foldedFrames int
+}
+
+func newCoroutineFrameFilter() *coroutineFrameFilter {
+	filter := coroutineFrameFilter{
+		printedFrames: make(map[FrameInfo]bool),
+	}
+
+	return &filter
+}
+
+// reset Forgets the previous frames, at the end of a stack trace.
+func (filter *coroutineFrameFilter) reset() {
+	filter.printedFrames = make(map[FrameInfo]bool)
+	filter.crossedBoundary = false
+	filter.foldedFrames = 0
+}
+
+// boundary Notes a coroutine boundary in the current stack trace.
+func (filter *coroutineFrameFilter) boundary() {
+	filter.crossedBoundary = true
+}
+
+// filter Returns the given original frames without the machinery frames,
+// and without the frames that were already printed before a boundary.
+func (filter *coroutineFrameFilter) filter(originalFrames []FrameInfo) []FrameInfo {
+	var keptFrames []FrameInfo
+	for _, frame := range originalFrames {
+		if frame.ClassName == coroutineBoundaryClassName {
+			filter.boundary()
+			keptFrames = append(keptFrames, frame)
+			continue
+		}
+
+		key := FrameInfo{
+			ClassName:  frame.ClassName,
+			MethodName: frame.MethodName,
+			LineNumber: frame.LineNumber,
+		}
+
+		if IsCoroutineMachineryFrame(&frame) ||
+			(filter.crossedBoundary && filter.printedFrames[key]) {
+			filter.foldedFrames++
+		} else {
+			filter.printedFrames[key] = true
+			keptFrames = append(keptFrames, frame)
+		}
+	}
+
+	return keptFrames
+}
+
+// annotate Returns the given retraced line of the given original frame with
+// the number of frames that were folded into it. Boundaries pass the number
+// on to the next frame.
+func (filter *coroutineFrameFilter) annotate(line string, frame *FrameInfo) string {
+	if frame.ClassName == coroutineBoundaryClassName {
+		return line
+	}
+
+	if note := filter.flush(); len(note) > 0 {
+		return appendLineNote(line, note)
+	}
+
+	return line
+}
+
+// flush Returns a note with the number of frames that have been folded since
+// the last printed frame, if any, and forgets them.
+func (filter *coroutineFrameFilter) flush() string {
+	folded := filter.foldedFrames
+	filter.foldedFrames = 0
+
+	switch {
+	case folded == 1:
+		return "1 coroutine frame folded"
+	case folded > 1:
+		return fmt.Sprintf("%d coroutine frames folded", folded)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,63 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const coroutineMapping = `com.example.Repository -> a:
+    1:1:java.lang.Object load(kotlin.coroutines.Continuation):30:30 -> a
+com.example.ViewModel -> b:
+    2:2:void refresh():12:12 -> a
+`
+
+func retraceCoroutines(trace string) string {
+	retrace := NewRetrace(strings.NewReader(coroutineMapping))
+	retrace.CoroutineFrames = true
+
+	result := bytes.NewBufferString("")
+	retrace.Retrace(strings.NewReader(trace), result)
+	return result.String()
+}
+
+func TestCoroutineFrames(t *testing.T) {
+	assert.Equal(t, `java.io.IOException: offline
+	at com.example.Repository.load(Repository.java:30)
+	at _COROUTINE._BOUNDARY._(CoroutineDebugging.kt)
+	at com.example.ViewModel.refresh(ViewModel.java:12) (2 coroutine frames folded)
+	(2 coroutine frames folded)
+`, retraceCoroutines(`java.io.IOException: offline
+	at a.a(SourceFile:1)
+	at kotlin.coroutines.jvm.internal.BaseContinuationImpl.resumeWith(ContinuationImpl.kt:33)
+	at _COROUTINE._BOUNDARY._(CoroutineDebugging.kt)
+	at a.a(SourceFile:1)
+	at b.a(SourceFile:2)
+	at kotlinx.coroutines.DispatchedTask.run(DispatchedTask.kt:106)
+	at kotlinx.coroutines.scheduling.CoroutineScheduler$Worker.run(CoroutineScheduler.kt:570)
+`))
+}
+
+func TestCoroutineBoundaryLine(t *testing.T) {
+	assert.Equal(t, `java.io.IOException: offline
+	at com.example.Repository.load(Repository.java:30)
+	(Coroutine boundary)
+	at com.example.ViewModel.refresh(ViewModel.java:12) (1 coroutine frame folded)
+Caused by: java.io.IOException: offline
+	at com.example.Repository.load(Repository.java:30)
+`, retraceCoroutines(`java.io.IOException: offline
+	at a.a(SourceFile:1)
+	(Coroutine boundary)
+	at a.a(SourceFile:1)
+	at b.a(SourceFile:2)
+Caused by: java.io.IOException: offline
+	at a.a(SourceFile:1)
+`))
+
+	assert.True(t, IsCoroutineBoundary("\tat _COROUTINE._BOUNDARY._(CoroutineDebugging.kt)\n"))
+	assert.False(t, IsCoroutineBoundary("\tat _COROUTINE._CREATION._(CoroutineDebugging.kt)\n"))
+	assert.True(t, IsCoroutineMachineryFrame(&FrameInfo{ClassName: "kotlinx.coroutines.DispatchedTask", MethodName: "run"}))
+	assert.False(t, IsCoroutineMachineryFrame(&FrameInfo{ClassName: "kotlinx.coroutines.flow.FlowKt", MethodName: "collect"}))
+}
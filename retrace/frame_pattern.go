@@ -15,8 +15,11 @@ const REGEX_CLASS_SLASH = `(?:[^\s\":./()]+/)*[^\s":./()]+`
 const REGEX_SOURCE_FILE = `(?:[^:()\d][^:()]*)?`
 const REGEX_LINE_NUMBER = `-?\b\d+\b`
 const REGEX_MEMBER = `<?[^\s\":./()]+>?`
+const REGEX_DESCRIPTOR_CLASS = `(?:[^\s\":./();\[]+/)*[^\s":./();\[]+`
 
 var REGEX_TYPE = REGEX_CLASS + `(?:\[\])*`
+var REGEX_TYPE_DESCRIPTOR = `\[*(?:[ZBCSIJFDV]|L` + REGEX_DESCRIPTOR_CLASS + `;)`
+var REGEX_METHOD_DESCRIPTOR = `\((?:` + REGEX_TYPE_DESCRIPTOR + `)*\)` + REGEX_TYPE_DESCRIPTOR
 var REGEX_ARGUMENTS = `(?:` + REGEX_TYPE + `(?:\s*,\s*` + REGEX_TYPE + ")*)?"
 
 /**
@@ -26,7 +29,7 @@ var REGEX_ARGUMENTS = `(?:` + REGEX_TYPE + `(?:\s*,\s*` + REGEX_TYPE + ")*)?"
 type FramePattern struct {
 	RegularExpression string
 
-	ExpressionTypes     [32]string
+	ExpressionTypes     [64]string
 	ExpressionTypeCount int
 	Pattern             regexp.Regexp
 	Verbose             bool
@@ -74,6 +77,10 @@ func NewFramePattern(regularExpression string, verbose bool) *FramePattern {
 			buffer.WriteString(REGEX_MEMBER)
 		case "a":
 			buffer.WriteString(REGEX_ARGUMENTS)
+		case "T":
+			buffer.WriteString(REGEX_TYPE_DESCRIPTOR)
+		case "D":
+			buffer.WriteString(REGEX_METHOD_DESCRIPTOR)
 		}
 
 		buffer.WriteString(")")
@@ -129,6 +136,11 @@ func (f *FramePattern) Parse(line string) FrameInfo {
 			methodName = result
 		case "a":
 			arguments = result
+		case "T":
+			javaType = ExternalType(result)
+		case "D":
+			javaType = ExternalMethodReturnType(result)
+			arguments = ExternalMethodArguments(result)
 		}
 	}
 
@@ -198,6 +210,10 @@ func (f *FramePattern) Format(line string, frameInfo FrameInfo) string {
 			}
 		case "a":
 			formattedBuffer.WriteString(frameInfo.Arguments)
+		case "T":
+			formattedBuffer.WriteString(InternalType(frameInfo.Type))
+		case "D":
+			formattedBuffer.WriteString(InternalMethodDescriptor(frameInfo.Type, frameInfo.Arguments))
 		}
 		lineIndex = endIndex
 	}
@@ -0,0 +1,64 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFramePatternDescriptors(t *testing.T) {
+	pattern := NewFramePattern(`L%C;->%m%D`, false)
+
+	frame := pattern.Parse("La/b/c;->d(ILjava/lang/String;)[La/e;")
+	assert.Equal(t, "a.b.c", frame.ClassName)
+	assert.Equal(t, "d", frame.MethodName)
+	assert.Equal(t, "int,java.lang.String", frame.Arguments)
+	assert.Equal(t, "a.e[]", frame.Type)
+
+	pattern = NewFramePattern(`%C\.%f:%T`, false)
+
+	frame = pattern.Parse("a/b/c.d:[[J")
+	assert.Equal(t, "a.b.c", frame.ClassName)
+	assert.Equal(t, "d", frame.FieldName)
+	assert.Equal(t, "long[][]", frame.Type)
+}
+
+const descriptorMapping = `com.example.Foo -> a.b.c:
+    com.example.Bar[] items -> e
+    void show(int,com.example.Bar) -> d
+com.example.Bar -> a.e:
+`
+
+func TestRetraceDescriptors(t *testing.T) {
+	retrace := NewRetrace(strings.NewReader(descriptorMapping))
+
+	result := bytes.NewBufferString("")
+	retrace.Retrace(strings.NewReader(`java.lang.NoSuchMethodError: No virtual method d(ILa/e;)V in class La/b/c; or its super classes
+java.lang.NoSuchFieldError: No instance field e of type [La/e; in class La/b/c; or its superclasses
+Rejecting invocation of La/b/c;->d(ILa/e;)V
+Location: a/b/c.d:(ILa/e;)V @3: invokevirtual
+Rejecting invocation of Landroid/view/View;->post(La/e;)Z
+`), result)
+
+	assert.Equal(t, `java.lang.NoSuchMethodError: No virtual method show(ILcom/example/Bar;)V in class Lcom/example/Foo; or its super classes
+java.lang.NoSuchFieldError: No instance field items of type [Lcom/example/Bar; in class Lcom/example/Foo; or its superclasses
+Rejecting invocation of Lcom/example/Foo;->show(ILcom/example/Bar;)V
+Location: com/example/Foo.show:(ILcom/example/Bar;)V @3: invokevirtual
+Rejecting invocation of Landroid/view/View;->post(Lcom/example/Bar;)Z
+`, result.String())
+}
+
+func TestRetraceDottedMethodMessages(t *testing.T) {
+	retrace := NewRetrace(strings.NewReader(`com.example.Baz -> b:
+    void qux() -> d
+`))
+
+	trace := `java.lang.NoSuchMethodError: a.b.c(I)V
+a.e: cannot call b.d()V
+`
+	result := bytes.NewBufferString("")
+	retrace.Retrace(strings.NewReader(trace), result)
+	assert.Equal(t, trace, result.String())
+}
@@ -0,0 +1,215 @@
+package retrace
+
+import (
+	"strings"
+)
+
+// ObfuscatedFieldInfo A field mapping, seen from its original name.
+type ObfuscatedFieldInfo struct {
+	ObfuscatedClassName string
+	ObfuscatedName      string
+
+	FieldInfo
+}
+
+// ObfuscatedMethodInfo A method mapping, seen from its original name.
+type ObfuscatedMethodInfo struct {
+	ObfuscatedClassName string
+	ObfuscatedName      string
+
+	MethodInfo
+
+	// Inlined whether the method was inlined into another method in this
+	// obfuscated line range, rather than being the method itself.
+	Inlined bool
+	// InlinedInto The method into which the method was inlined, if it was
+	// inlined.
+	InlinedInto *ObfuscatedMethodInfo
+	// HasInlinedMethods Whether other methods were inlined into the method
+	// in this obfuscated line range.
+	HasInlinedMethods bool
+}
+
+// InverseFrameRemapper This MappingProcessor indexes the mappings by their
+// original names, to find the obfuscated names of original classes and class
+// members, which is the reverse of FrameRemapper.
+type InverseFrameRemapper struct {
+	// ClassMap Original class name -> obfuscated class name.
+	ClassMap map[string]string
+	// ClassFieldMap Original class name -> original field name -> obfuscated fields.
+	ClassFieldMap  map[string]map[string][]*ObfuscatedFieldInfo
+	ClassMethodMap map[string]map[string][]*ObfuscatedMethodInfo
+
+	// The obfuscated class name of the class that is being processed.
+	newClassName string
+	// The method that was processed last, which becomes inlined if the next
+	// method has the same obfuscated line range.
+	lastMethodInfo *ObfuscatedMethodInfo
+}
+
+func NewInverseFrameRemapper() *InverseFrameRemapper {
+	inverse := InverseFrameRemapper{
+		ClassMap:       make(map[string]string),
+		ClassFieldMap:  make(map[string]map[string][]*ObfuscatedFieldInfo),
+		ClassMethodMap: make(map[string]map[string][]*ObfuscatedMethodInfo),
+	}
+
+	return &inverse
+}
+
+func (inverse *InverseFrameRemapper) ProcessClassMapping(className string, newClassName string) bool {
+	// Original class name -> obfuscated class name
+	inverse.ClassMap[className] = newClassName
+
+	inverse.newClassName = newClassName
+	inverse.lastMethodInfo = nil
+	return true
+}
+
+func (inverse *InverseFrameRemapper) ProcessFieldMapping(
+	className string,
+	fieldType string,
+	fieldName string,
+	newClassName string,
+	newFieldName string) {
+
+	// Original class name -> original field names
+	fieldMap, ok := inverse.ClassFieldMap[className]
+	if !ok {
+		fieldMap = make(map[string][]*ObfuscatedFieldInfo)
+		inverse.ClassFieldMap[className] = fieldMap
+	}
+
+	// Original field name -> obfuscated fields
+	fieldMap[fieldName] = append(fieldMap[fieldName], &ObfuscatedFieldInfo{
+		inverse.newClassName,
+		newFieldName,
+		FieldInfo{
+			OriginalClassName: className,
+			OriginalType:      fieldType,
+			OriginalName:      fieldName,
+		},
+	})
+
+	inverse.lastMethodInfo = nil
+}
+
+func (inverse *InverseFrameRemapper) ProcessMethodMapping(
+	className string,
+	firstLineNumber int,
+	lastLineNumber int,
+	methodType string,
+	methodName string,
+	arguments string,
+	newClassName string,
+	newFirstLineNumber int,
+	newLastLineNumber int,
+	newMethodName string) {
+
+	// Original class name -> original method names
+	methodMap, ok := inverse.ClassMethodMap[className]
+	if !ok {
+		methodMap = make(map[string][]*ObfuscatedMethodInfo)
+		inverse.ClassMethodMap[className] = methodMap
+	}
+
+	methodInfo := &ObfuscatedMethodInfo{
+		ObfuscatedClassName: inverse.newClassName,
+		ObfuscatedName:      newMethodName,
+		MethodInfo: MethodInfo{
+			newFirstLineNumber,
+			newLastLineNumber,
+			className,
+			firstLineNumber,
+			lastLineNumber,
+			methodType,
+			methodName,
+			arguments,
+		},
+	}
+
+	// Methods in the same obfuscated line range are inlined into the
+	// method that follows them.
+	if lastMethodInfo := inverse.lastMethodInfo; lastMethodInfo != nil &&
+		newLastLineNumber != 0 &&
+		lastMethodInfo.ObfuscatedName == newMethodName &&
+		lastMethodInfo.ObfuscatedFirstLineNumber == newFirstLineNumber &&
+		lastMethodInfo.ObfuscatedLastLineNumber == newLastLineNumber {
+		lastMethodInfo.Inlined = true
+		lastMethodInfo.InlinedInto = methodInfo
+		methodInfo.HasInlinedMethods = true
+	}
+
+	// Original method name -> obfuscated methods
+	methodMap[methodName] = append(methodMap[methodName], methodInfo)
+	inverse.lastMethodInfo = methodInfo
+}
+
+// GetObfuscatedClassName returns the obfuscated name of the given original
+// class, or the name itself if it wasn't obfuscated.
+func (inverse *InverseFrameRemapper) GetObfuscatedClassName(originalClassName string) string {
+	obfuscatedClassName, ok := inverse.ClassMap[originalClassName]
+	if !ok {
+		return originalClassName
+	} else {
+		return obfuscatedClassName
+	}
+}
+
+// FindFields returns the obfuscated fields with the given original class
+// name and field name.
+func (inverse *InverseFrameRemapper) FindFields(className string, fieldName string) []ObfuscatedFieldInfo {
+	var fieldInfos []ObfuscatedFieldInfo
+	for _, fieldInfo := range inverse.ClassFieldMap[className][fieldName] {
+		fieldInfos = append(fieldInfos, *fieldInfo)
+	}
+
+	return fieldInfos
+}
+
+// FindMethods returns the obfuscated methods with the given original class
+// name and method name, in the order of the mapping file. The method
+// arguments may be an empty wildcard, and the line number may be 0 if it is
+// not known. ObfuscatedLineNumbers returns the obfuscated line numbers of
+// the original line number in each method.
+func (inverse *InverseFrameRemapper) FindMethods(className string, methodName string, arguments string, lineNumber int) []ObfuscatedMethodInfo {
+	var methodInfos []ObfuscatedMethodInfo
+	for _, methodInfo := range inverse.ClassMethodMap[className][methodName] {
+		if arguments != "" && arguments != methodInfo.OriginalArguments {
+			continue
+		}
+
+		if lineNumber != 0 &&
+			methodInfo.OriginalLastLineNumber != 0 &&
+			(lineNumber < methodInfo.OriginalFirstLineNumber || methodInfo.OriginalLastLineNumber < lineNumber) {
+			continue
+		}
+
+		methodInfos = append(methodInfos, *methodInfo)
+	}
+
+	return methodInfos
+}
+
+// ObfuscateMethod returns the obfuscated methods of the given original
+// method reference, like "com.example.Foo.bar(int)" or "com.example.Foo.bar",
+// at the given original line number, which may be 0 if it is not known.
+func (inverse *InverseFrameRemapper) ObfuscateMethod(methodReference string, lineNumber int) []ObfuscatedMethodInfo {
+	arguments := ""
+	if argumentIndex1 := strings.Index(methodReference, "("); argumentIndex1 >= 0 {
+		argumentIndex2 := IndexOf(methodReference, ")", argumentIndex1+1)
+		if argumentIndex2 < 0 {
+			return nil
+		}
+
+		arguments = strings.ReplaceAll(methodReference[argumentIndex1+1:argumentIndex2], " ", "")
+		methodReference = methodReference[:argumentIndex1]
+	}
+
+	dotIndex := strings.LastIndex(methodReference, ".")
+	if dotIndex < 0 {
+		return nil
+	}
+
+	return inverse.FindMethods(methodReference[:dotIndex], methodReference[dotIndex+1:], arguments, lineNumber)
+}
@@ -0,0 +1,41 @@
+package retrace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInverseFrameRemapper(t *testing.T) {
+	inverse := NewInverseFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(mappingData)).Pump(inverse))
+
+	assert.Equal(t, "c", inverse.GetObfuscatedClassName("android.arch.core.executor.ArchTaskExecutor"))
+	assert.Equal(t, "com.example.Unknown", inverse.GetObfuscatedClassName("com.example.Unknown"))
+
+	fieldInfos := inverse.FindFields("android.arch.core.executor.DefaultTaskExecutor", "mMainHandler")
+	assert.Equal(t, 1, len(fieldInfos))
+	assert.Equal(t, "d", fieldInfos[0].ObfuscatedClassName)
+	assert.Equal(t, "pesfd", fieldInfos[0].ObfuscatedName)
+
+	// An original line in a range of lines maps to a single obfuscated line.
+	methodInfos := inverse.ObfuscateMethod("android.arch.core.internal.SafeIterableMap.remove(java.lang.Object)", 102)
+	assert.Equal(t, 1, len(methodInfos))
+	assert.Equal(t, "f", methodInfos[0].ObfuscatedClassName)
+	assert.Equal(t, "remove", methodInfos[0].ObfuscatedName)
+	assert.True(t, methodInfos[0].Inlined)
+	firstLineNumber, lastLineNumber := methodInfos[0].ObfuscatedLineNumbers(102)
+	assert.Equal(t, 3, firstLineNumber)
+	assert.Equal(t, 3, lastLineNumber)
+
+	// The line of an inlined call maps to all obfuscated lines of the call.
+	methodInfos = inverse.ObfuscateMethod("android.arch.core.internal.FastSafeIterableMap.remove", 56)
+	assert.Equal(t, 7, len(methodInfos))
+	assert.False(t, methodInfos[1].Inlined)
+	firstLineNumber, lastLineNumber = methodInfos[1].ObfuscatedLineNumbers(56)
+	assert.Equal(t, 2, firstLineNumber)
+	assert.Equal(t, 5, lastLineNumber)
+
+	assert.Equal(t, 0, len(inverse.ObfuscateMethod("android.arch.core.internal.FastSafeIterableMap.remove(int)", 0)))
+}
@@ -0,0 +1,148 @@
+package retrace
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The kinds of JVM methods that Kotlin generates for its constructs.
+const (
+	KotlinSuspendLambda   = "suspend lambda"
+	KotlinLambda          = "lambda"
+	KotlinAnonymousObject = "anonymous object"
+	KotlinDefaultArgs     = "default args"
+	KotlinPropertyGetter  = "property getter"
+	KotlinPropertySetter  = "property setter"
+	KotlinAccessor        = "accessor"
+	KotlinCompanion       = "companion"
+)
+
+// KotlinName The name of a JVM method as Kotlin developers think of it, like
+// "Foo.bar (suspend lambda)" for "Foo$bar$1.invokeSuspend".
+type KotlinName struct {
+	ClassName  string `json:"className"`
+	MethodName string `json:"methodName"`
+	// Kind The Kotlin construct for which the JVM method was generated, if
+	// any, like KotlinSuspendLambda.
+	Kind string `json:"kind,omitempty"`
+
+	JVMClassName  string `json:"jvmClassName"`
+	JVMMethodName string `json:"jvmMethodName"`
+}
+
+// Demangled returns whether the Kotlin name differs from the JVM name.
+func (name *KotlinName) Demangled() bool {
+	return name.ClassName != name.JVMClassName ||
+		name.MethodName != name.JVMMethodName ||
+		len(name.Kind) > 0
+}
+
+// String returns the name with the simple class name, like
+// "Foo.bar (suspend lambda)".
+func (name KotlinName) String() string {
+	className := name.ClassName[strings.LastIndex(name.ClassName, ".")+1:]
+	str := strings.ReplaceAll(className, "$", ".") + "." + name.MethodName
+	if len(name.Kind) > 0 {
+		str += " (" + name.Kind + ")"
+	}
+
+	return str
+}
+
+// For example "com.example.Foo$bar$1" or "com.example.Foo$bar$1$2", the
+// classes of lambdas and anonymous objects in functions, which start with a
+// lowercase letter, unlike nested classes.
+var kotlinFunctionClassPattern = regexp.MustCompile(`^(.*?)\$([a-z_][^$]*)(?:\$\d+)+$`)
+
+// For example "access$getBar$p", "access$setBar$cp" or "access$foo".
+var kotlinAccessorPattern = regexp.MustCompile(`^access\$(?:(get|set)([^$]+)\$c?p|(.+))$`)
+
+// For example "bar$lambda-0", "bar$lambda$0" or "lambda$bar$0".
+var kotlinLambdaPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(.+)\$lambda[-$]\d+$`),
+	regexp.MustCompile(`^lambda\$(.+)\$\d+$`),
+}
+
+// For example "foo-Ab12Cd3", the names of functions with parameters of inline
+// value classes, with a hash of their signatures.
+var kotlinMangledNamePattern = regexp.MustCompile(`^([^-]+)-[A-Za-z0-9_]{6,8}$`)
+
+// DemangleKotlin Returns the Kotlin name of the given JVM class name and
+// method name, after retracing.
+func DemangleKotlin(jvmClassName string, jvmMethodName string) KotlinName {
+	name := KotlinName{
+		ClassName:     jvmClassName,
+		MethodName:    jvmMethodName,
+		JVMClassName:  jvmClassName,
+		JVMMethodName: jvmMethodName,
+	}
+
+	// Lambdas and anonymous objects are classes named after their
+	// functions.
+	if match := kotlinFunctionClassPattern.FindStringSubmatch(jvmClassName); match != nil {
+		name.ClassName = match[1]
+		switch jvmMethodName {
+		case "invokeSuspend":
+			name.MethodName = match[2]
+			name.Kind = KotlinSuspendLambda
+		case "invoke":
+			name.MethodName = match[2]
+			name.Kind = KotlinLambda
+		default:
+			name.MethodName = match[2]
+			name.Kind = KotlinAnonymousObject
+		}
+	} else if strings.HasSuffix(jvmClassName, "$Companion") {
+		name.ClassName = strings.TrimSuffix(jvmClassName, "$Companion")
+		name.Kind = KotlinCompanion
+	}
+
+	methodName := name.MethodName
+	kind := ""
+	if match := kotlinAccessorPattern.FindStringSubmatch(methodName); match != nil {
+		switch match[1] {
+		case "get":
+			methodName = decapitalize(match[2])
+			kind = KotlinPropertyGetter
+		case "set":
+			methodName = decapitalize(match[2])
+			kind = KotlinPropertySetter
+		default:
+			methodName = match[3]
+			kind = KotlinAccessor
+		}
+	} else if strings.HasSuffix(methodName, "$default") {
+		methodName = strings.TrimSuffix(methodName, "$default")
+		kind = KotlinDefaultArgs
+	} else if strings.HasSuffix(methodName, "$suspendImpl") {
+		methodName = strings.TrimSuffix(methodName, "$suspendImpl")
+	} else {
+		for _, pattern := range kotlinLambdaPatterns {
+			if match := pattern.FindStringSubmatch(methodName); match != nil {
+				methodName = match[1]
+				kind = KotlinLambda
+				break
+			}
+		}
+	}
+
+	if match := kotlinMangledNamePattern.FindStringSubmatch(methodName); match != nil {
+		methodName = match[1]
+	}
+
+	name.MethodName = methodName
+	if len(kind) > 0 {
+		// The kind of the method is more specific than the kind of its
+		// class.
+		name.Kind = kind
+	}
+
+	return name
+}
+
+func decapitalize(name string) string {
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(first)) + name[size:]
+}
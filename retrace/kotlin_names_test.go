@@ -0,0 +1,59 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemangleKotlin(t *testing.T) {
+	assert.Equal(t, "Foo.bar (suspend lambda)", DemangleKotlin("com.example.Foo$bar$1", "invokeSuspend").String())
+	assert.Equal(t, "Foo.bar (lambda)", DemangleKotlin("com.example.Foo$bar$1$2", "invoke").String())
+	assert.Equal(t, "Foo.bar (anonymous object)", DemangleKotlin("com.example.Foo$bar$1", "onClick").String())
+	assert.Equal(t, "Foo.bar (default args)", DemangleKotlin("com.example.Foo", "bar$default").String())
+	assert.Equal(t, "Foo.bar (lambda)", DemangleKotlin("com.example.Foo", "bar$lambda-0").String())
+	assert.Equal(t, "Foo.count (property getter)", DemangleKotlin("com.example.Foo", "access$getCount$p").String())
+	assert.Equal(t, "Foo.create (companion)", DemangleKotlin("com.example.Foo$Companion", "create").String())
+	assert.Equal(t, "Foo.pay", DemangleKotlin("com.example.Foo", "pay-Ab12Cd3").String())
+
+	name := DemangleKotlin("com.example.Foo$Inner", "run")
+	assert.False(t, name.Demangled())
+	assert.Equal(t, "Foo.Inner.run", name.String())
+}
+
+const kotlinMapping = `com.example.Foo$bar$1 -> a:
+    1:1:java.lang.Object invokeSuspend(java.lang.Object):20:20 -> a
+com.example.Foo -> b:
+    2:2:void bar$default(com.example.Foo,int,int,java.lang.Object):10:10 -> a
+`
+
+func TestRetraceKotlinNames(t *testing.T) {
+	trace := `java.lang.IllegalStateException: boom
+	at a.a(SourceFile:1)
+	at b.a(SourceFile:2)
+`
+
+	retrace := NewRetrace(strings.NewReader(kotlinMapping))
+	retrace.KotlinNames = true
+
+	result := bytes.NewBufferString("")
+	retrace.Retrace(strings.NewReader(trace), result)
+	assert.Equal(t, `java.lang.IllegalStateException: boom
+	at com.example.Foo.bar(Foo.java:20) (suspend lambda)
+	at com.example.Foo.bar(Foo.java:10) (default args)
+`, result.String())
+
+	retrace = NewRetrace(strings.NewReader(kotlinMapping))
+	retrace.KotlinNames = true
+
+	lines := retrace.RetraceLines(strings.NewReader(trace))
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, "\tat com.example.Foo.bar(Foo.java:20) (suspend lambda)", lines[1].Line)
+	assert.Equal(t, 1, len(lines[1].Frames))
+	assert.Equal(t, "com.example.Foo$bar$1", lines[1].Frames[0].ClassName)
+	assert.Equal(t, "invokeSuspend", lines[1].Frames[0].MethodName)
+	assert.Equal(t, "com.example.Foo$bar$1", lines[1].Frames[0].Kotlin.JVMClassName)
+	assert.Equal(t, KotlinSuspendLambda, lines[1].Frames[0].Kotlin.Kind)
+}
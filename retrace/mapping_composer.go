@@ -0,0 +1,367 @@
+package retrace
+
+// ComposeMappings Composes two mappings of a build that was obfuscated twice,
+// like R8's --compose mode does, and passes the result to the given mapping
+// processor.
+//
+// Parameters:
+//
+//	first     the mapping of the first stage, from original names to
+//	          intermediate names.
+//	second    the mapping of the second stage, from intermediate names to
+//	          final names.
+//	processor the processor that receives the mapping from original names
+//	          to final names.
+//
+// The line numbers of both stages are composed, and the inlined methods of
+// both stages are kept. Classes that the second stage removed are left out.
+func ComposeMappings(first *FrameRemapper, second *FrameRemapper, processor MappingProcessor) {
+	composer := mappingComposer{
+		first:     first,
+		processor: processor,
+	}
+	composer.commentProcessor, _ = processor.(MappingCommentProcessor)
+
+	second.Pump(&composer)
+	composer.flushMethods()
+}
+
+// composedFrame A frame of an inline group in terms of the original names.
+type composedFrame struct {
+	className  string
+	methodType string
+	methodName string
+	arguments  string
+	lineNumber int
+}
+
+func (frame *composedFrame) sameMethod(other *composedFrame) bool {
+	return frame.className == other.className &&
+		frame.methodType == other.methodType &&
+		frame.methodName == other.methodName &&
+		frame.arguments == other.arguments
+}
+
+// mappingComposer This MappingProcessor receives the mapping of the second
+// stage and passes it on in terms of the original names of the first stage.
+type mappingComposer struct {
+	first            *FrameRemapper
+	processor        MappingProcessor
+	commentProcessor MappingCommentProcessor
+
+	// The class that is being composed.
+	className         string
+	inClassMembers    bool
+	skipClassComments bool
+
+	// The inline group that is being collected, in terms of the
+	// intermediate names.
+	methodGroup        []MethodInfo
+	newMethodName      string
+	newFirstLineNumber int
+	newLastLineNumber  int
+}
+
+func (composer *mappingComposer) ProcessClassMapping(className string, newClassName string) bool {
+	composer.flushMethods()
+
+	composer.className = composer.first.GetOriginalClassName(className)
+	interested := composer.processor.ProcessClassMapping(composer.className, newClassName)
+	composer.inClassMembers = false
+
+	// The metadata of the first stage, like the source file, describes the
+	// original class best.
+	firstComments := composer.first.ClassComments[composer.className]
+	composer.skipClassComments = len(firstComments) > 0
+	if interested {
+		pumpComments(composer.commentProcessor, firstComments)
+	}
+
+	return interested
+}
+
+func (composer *mappingComposer) ProcessFieldMapping(
+	className string,
+	fieldType string,
+	fieldName string,
+	newClassName string,
+	newFieldName string) {
+
+	composer.flushMethods()
+	composer.inClassMembers = true
+
+	originalClassName := composer.first.GetOriginalClassName(className)
+	originalType := getOriginalType(composer.first, fieldType)
+
+	// Find the fields of the first stage.
+	found := false
+	if fieldSet, ok := composer.first.ClassFieldMap[originalClassName][fieldName]; ok {
+		for _, fieldInfo := range fieldSet.Values() {
+			if !fieldInfo.Matches(originalType) {
+				continue
+			}
+
+			composer.processor.ProcessFieldMapping(
+				fieldInfo.OriginalClassName,
+				fieldInfo.OriginalType,
+				fieldInfo.OriginalName,
+				composer.className,
+				newFieldName,
+			)
+			found = true
+		}
+	}
+
+	// The first stage didn't rename the field.
+	if !found {
+		composer.processor.ProcessFieldMapping(
+			originalClassName,
+			originalType,
+			fieldName,
+			composer.className,
+			newFieldName,
+		)
+	}
+}
+
+func (composer *mappingComposer) ProcessMethodMapping(
+	className string,
+	firstLineNumber int,
+	lastLineNumber int,
+	methodType string,
+	methodName string,
+	arguments string,
+	newClassName string,
+	newFirstLineNumber int,
+	newLastLineNumber int,
+	newMethodName string) {
+
+	composer.inClassMembers = true
+
+	// Does the method start a new inline group ?
+	if len(composer.methodGroup) > 0 &&
+		(newMethodName != composer.newMethodName ||
+			newFirstLineNumber != composer.newFirstLineNumber ||
+			newLastLineNumber != composer.newLastLineNumber) {
+		composer.flushMethods()
+	}
+
+	composer.newMethodName = newMethodName
+	composer.newFirstLineNumber = newFirstLineNumber
+	composer.newLastLineNumber = newLastLineNumber
+	composer.methodGroup = append(composer.methodGroup, MethodInfo{
+		newFirstLineNumber,
+		newLastLineNumber,
+		className,
+		firstLineNumber,
+		lastLineNumber,
+		methodType,
+		methodName,
+		arguments,
+	})
+}
+
+func (composer *mappingComposer) ProcessComment(comment string) {
+	composer.flushMethods()
+
+	if composer.commentProcessor == nil {
+		return
+	}
+
+	if !composer.inClassMembers && composer.skipClassComments {
+		return
+	}
+
+	// The header of the second stage, like its map id, doesn't describe
+	// the composed mapping.
+	if len(composer.className) == 0 {
+		return
+	}
+
+	composer.commentProcessor.ProcessComment(comment)
+}
+
+// flushMethods Composes the collected inline group and passes it on.
+func (composer *mappingComposer) flushMethods() {
+	if len(composer.methodGroup) == 0 {
+		return
+	}
+
+	if composer.newFirstLineNumber == 0 && composer.newLastLineNumber == 0 {
+		composer.flushMethodsWithoutLineNumbers()
+	} else {
+		composer.flushMethodsWithLineNumbers()
+	}
+
+	composer.methodGroup = composer.methodGroup[:0]
+}
+
+// flushMethodsWithoutLineNumbers Passes on the original methods of the
+// collected methods, which don't have any line numbers to compose.
+func (composer *mappingComposer) flushMethodsWithoutLineNumbers() {
+	for _, methodInfo := range composer.methodGroup {
+		var frames []composedFrame
+		for _, frame := range composer.composeFrames(&methodInfo, 0) {
+			// Leave out the methods that the first stage inlined.
+			if frame.className != composer.first.GetOriginalClassName(methodInfo.OriginalClassName) {
+				continue
+			}
+			duplicate := false
+			for _, other := range frames {
+				duplicate = duplicate || frame.sameMethod(&other)
+			}
+			if !duplicate {
+				frames = append(frames, frame)
+			}
+		}
+
+		for _, frame := range frames {
+			composer.processor.ProcessMethodMapping(
+				frame.className,
+				0,
+				0,
+				frame.methodType,
+				frame.methodName,
+				frame.arguments,
+				composer.className,
+				0,
+				0,
+				composer.newMethodName,
+			)
+		}
+	}
+}
+
+// flushMethodsWithLineNumbers Composes the collected inline group line by
+// line, and passes on the ranges of lines that compose the same way.
+func (composer *mappingComposer) flushMethodsWithLineNumbers() {
+	var (
+		rangeFrames      []composedFrame
+		rangeFirstLine   int
+		rangeLastLine    int
+		rangeIncrements  []bool
+		rangeHasNextLine bool
+	)
+
+	for newLineNumber := composer.newFirstLineNumber; newLineNumber <= composer.newLastLineNumber; newLineNumber++ {
+		var frames []composedFrame
+		for index := range composer.methodGroup {
+			methodInfo := &composer.methodGroup[index]
+			frames = append(frames, composer.composeFrames(methodInfo, methodInfo.OriginalLineNumber(newLineNumber))...)
+		}
+
+		// Can the line extend the current range ?
+		if rangeFrames != nil && len(frames) == len(rangeFrames) {
+			fits := true
+			increments := make([]bool, len(frames))
+			offset := newLineNumber - rangeFirstLine
+			for index := range frames {
+				delta := frames[index].lineNumber - rangeFrames[index].lineNumber
+				increments[index] = delta == offset
+				fits = fits && frames[index].sameMethod(&rangeFrames[index]) &&
+					(delta == 0 || delta == offset) &&
+					(!rangeHasNextLine || increments[index] == rangeIncrements[index])
+			}
+
+			if fits {
+				rangeLastLine = newLineNumber
+				rangeIncrements = increments
+				rangeHasNextLine = true
+				continue
+			}
+		}
+
+		composer.processFrames(rangeFrames, rangeFirstLine, rangeLastLine, rangeIncrements)
+
+		rangeFrames = frames
+		rangeFirstLine = newLineNumber
+		rangeLastLine = newLineNumber
+		rangeIncrements = nil
+		rangeHasNextLine = false
+	}
+
+	composer.processFrames(rangeFrames, rangeFirstLine, rangeLastLine, rangeIncrements)
+}
+
+// processFrames Passes on the given composed frames, for the given range of
+// new line numbers.
+func (composer *mappingComposer) processFrames(frames []composedFrame, newFirstLineNumber int, newLastLineNumber int, increments []bool) {
+	for index, frame := range frames {
+		lastLineNumber := frame.lineNumber
+		if increments != nil && increments[index] {
+			lastLineNumber += newLastLineNumber - newFirstLineNumber
+		}
+
+		composer.processor.ProcessMethodMapping(
+			frame.className,
+			frame.lineNumber,
+			lastLineNumber,
+			frame.methodType,
+			frame.methodName,
+			frame.arguments,
+			composer.className,
+			newFirstLineNumber,
+			newLastLineNumber,
+			composer.newMethodName,
+		)
+	}
+}
+
+// composeFrames Returns the original frames of the given method of the
+// second stage at the given intermediate line number, innermost first.
+func (composer *mappingComposer) composeFrames(methodInfo *MethodInfo, lineNumber int) []composedFrame {
+	first := composer.first
+	originalClassName := first.GetOriginalClassName(methodInfo.OriginalClassName)
+	originalType := getOriginalType(first, methodInfo.OriginalType)
+	originalArguments := getOriginalArguments(first, methodInfo.OriginalArguments)
+
+	// Collect the inline groups of the first stage at the line number,
+	// which are the matching methods with the same obfuscated range, and
+	// keep the ones of which the outermost method has the signature.
+	var frames []composedFrame
+	var group []MethodInfo
+	flushGroup := func() {
+		if len(group) > 0 && group[len(group)-1].Matches(lineNumber, originalType, originalArguments) {
+			for _, firstMethodInfo := range group {
+				frames = append(frames, composedFrame{
+					firstMethodInfo.OriginalClassName,
+					firstMethodInfo.OriginalType,
+					firstMethodInfo.OriginalName,
+					firstMethodInfo.OriginalArguments,
+					firstMethodInfo.OriginalLineNumber(lineNumber),
+				})
+			}
+		}
+		group = group[:0]
+	}
+
+	if methodSet, ok := first.ClassMethodMap[originalClassName][methodInfo.OriginalName]; ok {
+		for _, firstMethodInfo := range methodSet.Values() {
+			if !firstMethodInfo.Matches(lineNumber, "", "") {
+				continue
+			}
+
+			if len(group) > 0 &&
+				(firstMethodInfo.ObfuscatedLastLineNumber == 0 ||
+					group[0].ObfuscatedFirstLineNumber != firstMethodInfo.ObfuscatedFirstLineNumber ||
+					group[0].ObfuscatedLastLineNumber != firstMethodInfo.ObfuscatedLastLineNumber) {
+				flushGroup()
+			}
+			group = append(group, firstMethodInfo)
+		}
+		flushGroup()
+	}
+
+	// The first stage didn't rename the method.
+	if len(frames) == 0 {
+		frames = append(frames, composedFrame{
+			originalClassName,
+			originalType,
+			methodInfo.OriginalName,
+			originalArguments,
+			lineNumber,
+		})
+	}
+
+	return frames
+}
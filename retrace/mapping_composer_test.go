@@ -0,0 +1,61 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposeMappings(t *testing.T) {
+	first := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(`com.example.Foo -> a.a:
+# {"id":"sourceFile","fileName":"Foo.kt"}
+    int count -> a
+    1:3:void bar(com.example.Baz):10:12 -> a
+    4:4:void com.example.Baz.helper():30:30 -> a
+    4:4:void bar(com.example.Baz):13 -> a
+com.example.Baz -> a.b:
+    void helper() -> b
+`)).Pump(first))
+
+	second := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(`# pg_map_id: 1a2b3c4
+a.a -> x:
+# {"id":"sourceFile","fileName":"SourceFile"}
+    int a -> c
+    1:4:void a(a.b):1:4 -> d
+    5:5:void a.b.b():1:1 -> d
+    5:5:void a(a.b):2 -> d
+a.b -> y:
+    void b() -> e
+`)).Pump(second))
+
+	output := bytes.NewBufferString("")
+	writer := NewMappingWriter(output)
+	ComposeMappings(first, second, writer)
+	assert.NoError(t, writer.Flush())
+
+	assert.Equal(t, `com.example.Foo -> x:
+# {"id":"sourceFile","fileName":"Foo.kt"}
+    int count -> c
+    1:3:void bar(com.example.Baz):10:12 -> d
+    4:4:void com.example.Baz.helper():30:30 -> d
+    4:4:void bar(com.example.Baz):13:13 -> d
+    5:5:void com.example.Baz.helper():1:1 -> d
+    5:5:void bar(com.example.Baz):11:11 -> d
+com.example.Baz -> y:
+    void helper() -> e
+`, output.String())
+
+	// Retracing through the composed mapping gives the original frames.
+	composed := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(output).Pump(composed))
+	frames := composed.Transform(&FrameInfo{ClassName: "x", MethodName: "d", LineNumber: 4})
+	assert.Equal(t, 2, len(frames))
+	assert.Equal(t, "helper", frames[0].MethodName)
+	assert.Equal(t, 30, frames[0].LineNumber)
+	assert.Equal(t, "bar", frames[1].MethodName)
+	assert.Equal(t, 13, frames[1].LineNumber)
+}
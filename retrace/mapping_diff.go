@@ -0,0 +1,298 @@
+package retrace
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DiffChange The kind of change of a class or class member between two
+// mappings.
+type DiffChange string
+
+const (
+	DiffAdded   DiffChange = "added"
+	DiffRemoved DiffChange = "removed"
+	// DiffRenamed The obfuscated name changed.
+	DiffRenamed DiffChange = "renamed"
+	// DiffMoved The original line range of a method changed.
+	DiffMoved DiffChange = "moved"
+	// DiffMembersChanged Only the members of the class changed.
+	DiffMembersChanged DiffChange = "members-changed"
+)
+
+// MappingDiff The differences between the mappings of two releases.
+type MappingDiff struct {
+	// CommonClassCount The number of original classes in both mappings.
+	CommonClassCount int `json:"commonClassCount"`
+	// RenamedClassCount The number of common classes with a different
+	// obfuscated name.
+	RenamedClassCount int         `json:"renamedClassCount"`
+	Classes           []ClassDiff `json:"classes"`
+}
+
+// ClassDiff The differences of a single original class.
+type ClassDiff struct {
+	ClassName         string       `json:"className"`
+	Change            DiffChange   `json:"change"`
+	OldObfuscatedName string       `json:"oldObfuscatedName,omitempty"`
+	NewObfuscatedName string       `json:"newObfuscatedName,omitempty"`
+	Members           []MemberDiff `json:"members,omitempty"`
+}
+
+// MemberDiff The difference of a single original class member, like
+// "int count" or "void bar(int)".
+type MemberDiff struct {
+	Member             string     `json:"member"`
+	Change             DiffChange `json:"change"`
+	OldObfuscatedName  string     `json:"oldObfuscatedName,omitempty"`
+	NewObfuscatedName  string     `json:"newObfuscatedName,omitempty"`
+	OldFirstLineNumber int        `json:"oldFirstLineNumber,omitempty"`
+	OldLastLineNumber  int        `json:"oldLastLineNumber,omitempty"`
+	NewFirstLineNumber int        `json:"newFirstLineNumber,omitempty"`
+	NewLastLineNumber  int        `json:"newLastLineNumber,omitempty"`
+}
+
+// memberSummary The obfuscated names and original line range of a class
+// member in one mapping.
+type memberSummary struct {
+	name            string
+	isMethod        bool
+	obfuscatedNames []string
+	firstLineNumber int
+	lastLineNumber  int
+}
+
+func (summary *memberSummary) addObfuscatedName(obfuscatedName string) {
+	for _, name := range summary.obfuscatedNames {
+		if name == obfuscatedName {
+			return
+		}
+	}
+	summary.obfuscatedNames = append(summary.obfuscatedNames, obfuscatedName)
+}
+
+// DiffMappings Compares the mappings of two releases by their original names.
+func DiffMappings(oldMapping *InverseFrameRemapper, newMapping *InverseFrameRemapper) *MappingDiff {
+	diff := MappingDiff{}
+
+	var classNames []string
+	for className := range oldMapping.ClassMap {
+		classNames = append(classNames, className)
+	}
+	for className := range newMapping.ClassMap {
+		if _, ok := oldMapping.ClassMap[className]; !ok {
+			classNames = append(classNames, className)
+		}
+	}
+	sort.Strings(classNames)
+
+	for _, className := range classNames {
+		oldObfuscatedName, inOld := oldMapping.ClassMap[className]
+		newObfuscatedName, inNew := newMapping.ClassMap[className]
+
+		classDiff := ClassDiff{
+			ClassName:         className,
+			OldObfuscatedName: oldObfuscatedName,
+			NewObfuscatedName: newObfuscatedName,
+		}
+
+		if !inOld {
+			classDiff.Change = DiffAdded
+		} else if !inNew {
+			classDiff.Change = DiffRemoved
+		} else {
+			diff.CommonClassCount++
+			classDiff.Members = diffMembers(
+				summarizeMembers(oldMapping, className),
+				summarizeMembers(newMapping, className))
+
+			if oldObfuscatedName != newObfuscatedName {
+				diff.RenamedClassCount++
+				classDiff.Change = DiffRenamed
+			} else if len(classDiff.Members) > 0 {
+				classDiff.Change = DiffMembersChanged
+			} else {
+				continue
+			}
+		}
+
+		diff.Classes = append(diff.Classes, classDiff)
+	}
+
+	return &diff
+}
+
+// summarizeMembers Returns the members that the given class itself contains
+// in the given mapping, by their original signatures.
+func summarizeMembers(mapping *InverseFrameRemapper, className string) map[string]*memberSummary {
+	obfuscatedClassName := mapping.ClassMap[className]
+	members := make(map[string]*memberSummary)
+
+	member := func(signature string, name string, isMethod bool) *memberSummary {
+		summary, ok := members[signature]
+		if !ok {
+			summary = &memberSummary{name: name, isMethod: isMethod}
+			members[signature] = summary
+		}
+		return summary
+	}
+
+	for _, fieldInfos := range mapping.ClassFieldMap[className] {
+		for _, fieldInfo := range fieldInfos {
+			if fieldInfo.ObfuscatedClassName != obfuscatedClassName {
+				continue
+			}
+
+			member(fieldInfo.OriginalType+" "+fieldInfo.OriginalName, fieldInfo.OriginalName, false).addObfuscatedName(fieldInfo.ObfuscatedName)
+		}
+	}
+
+	for _, methodInfos := range mapping.ClassMethodMap[className] {
+		for _, methodInfo := range methodInfos {
+			// Skip the copies of the method that were inlined elsewhere.
+			if methodInfo.Inlined || methodInfo.ObfuscatedClassName != obfuscatedClassName {
+				continue
+			}
+
+			summary := member(methodInfo.OriginalType+" "+methodInfo.OriginalName+"("+methodInfo.OriginalArguments+")", methodInfo.OriginalName, true)
+			summary.addObfuscatedName(methodInfo.ObfuscatedName)
+
+			if methodInfo.OriginalFirstLineNumber != 0 &&
+				(summary.firstLineNumber == 0 || methodInfo.OriginalFirstLineNumber < summary.firstLineNumber) {
+				summary.firstLineNumber = methodInfo.OriginalFirstLineNumber
+			}
+			if methodInfo.OriginalLastLineNumber > summary.lastLineNumber {
+				summary.lastLineNumber = methodInfo.OriginalLastLineNumber
+			}
+		}
+	}
+
+	for _, summary := range members {
+		sort.Strings(summary.obfuscatedNames)
+	}
+
+	return members
+}
+
+func diffMembers(oldMembers map[string]*memberSummary, newMembers map[string]*memberSummary) []MemberDiff {
+	var signatures []string
+	for signature := range oldMembers {
+		signatures = append(signatures, signature)
+	}
+	for signature := range newMembers {
+		if _, ok := oldMembers[signature]; !ok {
+			signatures = append(signatures, signature)
+		}
+	}
+	sort.Strings(signatures)
+
+	var memberDiffs []MemberDiff
+	for _, signature := range signatures {
+		oldMember, inOld := oldMembers[signature]
+		newMember, inNew := newMembers[signature]
+
+		if !inOld {
+			memberDiffs = append(memberDiffs, MemberDiff{
+				Member:             signature,
+				Change:             DiffAdded,
+				NewObfuscatedName:  strings.Join(newMember.obfuscatedNames, ","),
+				NewFirstLineNumber: newMember.firstLineNumber,
+				NewLastLineNumber:  newMember.lastLineNumber,
+			})
+			continue
+		}
+
+		if !inNew {
+			memberDiffs = append(memberDiffs, MemberDiff{
+				Member:             signature,
+				Change:             DiffRemoved,
+				OldObfuscatedName:  strings.Join(oldMember.obfuscatedNames, ","),
+				OldFirstLineNumber: oldMember.firstLineNumber,
+				OldLastLineNumber:  oldMember.lastLineNumber,
+			})
+			continue
+		}
+
+		oldObfuscatedName := strings.Join(oldMember.obfuscatedNames, ",")
+		newObfuscatedName := strings.Join(newMember.obfuscatedNames, ",")
+		if oldObfuscatedName != newObfuscatedName {
+			memberDiffs = append(memberDiffs, MemberDiff{
+				Member:            signature,
+				Change:            DiffRenamed,
+				OldObfuscatedName: oldObfuscatedName,
+				NewObfuscatedName: newObfuscatedName,
+			})
+		}
+
+		if oldMember.firstLineNumber != newMember.firstLineNumber ||
+			oldMember.lastLineNumber != newMember.lastLineNumber {
+			memberDiffs = append(memberDiffs, MemberDiff{
+				Member:             signature,
+				Change:             DiffMoved,
+				OldFirstLineNumber: oldMember.firstLineNumber,
+				OldLastLineNumber:  oldMember.lastLineNumber,
+				NewFirstLineNumber: newMember.firstLineNumber,
+				NewLastLineNumber:  newMember.lastLineNumber,
+			})
+		}
+	}
+
+	return memberDiffs
+}
+
+// WriteSummary Writes a human readable summary of the differences.
+func (diff *MappingDiff) WriteSummary(writer io.Writer) error {
+	var buffer strings.Builder
+
+	counts := make(map[DiffChange]int)
+	memberCounts := make(map[DiffChange]int)
+	for _, classDiff := range diff.Classes {
+		counts[classDiff.Change]++
+		for _, memberDiff := range classDiff.Members {
+			memberCounts[memberDiff.Change]++
+		}
+	}
+
+	fmt.Fprintf(&buffer, "Classes: %d added, %d removed, %d renamed\n",
+		counts[DiffAdded], counts[DiffRemoved], counts[DiffRenamed])
+	fmt.Fprintf(&buffer, "Members: %d added, %d removed, %d renamed, %d moved\n",
+		memberCounts[DiffAdded], memberCounts[DiffRemoved], memberCounts[DiffRenamed], memberCounts[DiffMoved])
+	if diff.CommonClassCount > 0 {
+		fmt.Fprintf(&buffer, "Stable: %.1f%% of %d common classes kept their obfuscated names\n",
+			100*float64(diff.CommonClassCount-diff.RenamedClassCount)/float64(diff.CommonClassCount), diff.CommonClassCount)
+	}
+
+	for _, classDiff := range diff.Classes {
+		buffer.WriteString("\n")
+		switch classDiff.Change {
+		case DiffAdded:
+			fmt.Fprintf(&buffer, "+ %s -> %s\n", classDiff.ClassName, classDiff.NewObfuscatedName)
+		case DiffRemoved:
+			fmt.Fprintf(&buffer, "- %s -> %s\n", classDiff.ClassName, classDiff.OldObfuscatedName)
+		case DiffRenamed:
+			fmt.Fprintf(&buffer, "~ %s: %s -> %s\n", classDiff.ClassName, classDiff.OldObfuscatedName, classDiff.NewObfuscatedName)
+		default:
+			fmt.Fprintf(&buffer, "  %s -> %s\n", classDiff.ClassName, classDiff.NewObfuscatedName)
+		}
+
+		for _, memberDiff := range classDiff.Members {
+			switch memberDiff.Change {
+			case DiffAdded:
+				fmt.Fprintf(&buffer, "    + %s -> %s\n", memberDiff.Member, memberDiff.NewObfuscatedName)
+			case DiffRemoved:
+				fmt.Fprintf(&buffer, "    - %s -> %s\n", memberDiff.Member, memberDiff.OldObfuscatedName)
+			case DiffRenamed:
+				fmt.Fprintf(&buffer, "    ~ %s: %s -> %s\n", memberDiff.Member, memberDiff.OldObfuscatedName, memberDiff.NewObfuscatedName)
+			case DiffMoved:
+				fmt.Fprintf(&buffer, "    > %s: lines %d-%d -> %d-%d\n", memberDiff.Member,
+					memberDiff.OldFirstLineNumber, memberDiff.OldLastLineNumber,
+					memberDiff.NewFirstLineNumber, memberDiff.NewLastLineNumber)
+			}
+		}
+	}
+
+	_, err := io.WriteString(writer, buffer.String())
+	return err
+}
@@ -0,0 +1,74 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readInverseMapping(t *testing.T, mapping string) *InverseFrameRemapper {
+	inverse := NewInverseFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(mapping)).Pump(inverse))
+	return inverse
+}
+
+func TestDiffMappings(t *testing.T) {
+	oldMapping := readInverseMapping(t, `com.example.Foo -> a:
+    int count -> a
+    1:3:void bar(int):10:12 -> a
+    4:4:void com.example.Baz.helper():30:30 -> b
+    4:4:void baz():20 -> b
+com.example.Old -> b:
+    void run() -> a
+com.example.Same -> c:
+    void run() -> a
+`)
+	newMapping := readInverseMapping(t, `com.example.Foo -> d:
+    int count -> a
+    long total -> b
+    1:3:void bar(int):11:13 -> c
+com.example.New -> b:
+    void run() -> a
+com.example.Same -> c:
+    void run() -> a
+`)
+
+	diff := DiffMappings(oldMapping, newMapping)
+	assert.Equal(t, 2, diff.CommonClassCount)
+	assert.Equal(t, 1, diff.RenamedClassCount)
+	assert.Equal(t, []ClassDiff{
+		{
+			ClassName:         "com.example.Foo",
+			Change:            DiffRenamed,
+			OldObfuscatedName: "a",
+			NewObfuscatedName: "d",
+			Members: []MemberDiff{
+				{Member: "long total", Change: DiffAdded, NewObfuscatedName: "b"},
+				{Member: "void bar(int)", Change: DiffRenamed, OldObfuscatedName: "a", NewObfuscatedName: "c"},
+				{Member: "void bar(int)", Change: DiffMoved, OldFirstLineNumber: 10, OldLastLineNumber: 12, NewFirstLineNumber: 11, NewLastLineNumber: 13},
+				{Member: "void baz()", Change: DiffRemoved, OldObfuscatedName: "b", OldFirstLineNumber: 20, OldLastLineNumber: 20},
+			},
+		},
+		{ClassName: "com.example.New", Change: DiffAdded, NewObfuscatedName: "b"},
+		{ClassName: "com.example.Old", Change: DiffRemoved, OldObfuscatedName: "b"},
+	}, diff.Classes)
+
+	summary := bytes.NewBufferString("")
+	assert.NoError(t, diff.WriteSummary(summary))
+	assert.Equal(t, `Classes: 1 added, 1 removed, 1 renamed
+Members: 1 added, 1 removed, 1 renamed, 1 moved
+Stable: 50.0% of 2 common classes kept their obfuscated names
+
+~ com.example.Foo: a -> d
+    + long total -> b
+    ~ void bar(int): a -> c
+    > void bar(int): lines 10-12 -> 11-13
+    - void baz() -> b
+
++ com.example.New -> b
+
+- com.example.Old -> b
+`, summary.String())
+}
@@ -0,0 +1,164 @@
+package retrace
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// MappingFilter This MappingProcessor passes on the mappings of a subset of
+// the classes to another MappingProcessor, for example to share only the
+// mapping of an SDK with its vendor.
+//
+// A class is kept with all its class members if its original name matches
+// one of the ClassNames. Other classes are only kept if their obfuscated
+// names are among the ReferencedNames, and then only with the class members
+// whose "obfuscatedClass.obfuscatedMember" names are among them too, so
+// that the traces that reference them can still be retraced.
+type MappingFilter struct {
+	Processor MappingProcessor
+	// ClassNames Original class names or package names. A name matches the
+	// class itself, its inner classes, and the classes in the package and
+	// its subpackages, so "com.example" matches "com.example.Foo" and
+	// "com.example.sub.Bar$Baz".
+	ClassNames []string
+	// ReferencedNames Obfuscated class names and class member names, like
+	// "a.b" and "a.b.c", as returned by CollectTraceNames. May be nil.
+	ReferencedNames map[string]bool
+
+	// The obfuscated name of the class that is being filtered.
+	obfuscatedClassName string
+	// Whether all class members of that class are kept.
+	keepAllMembers bool
+	// Whether the class member mapping that was processed last was kept,
+	// so its comments should be kept too.
+	keepComments bool
+}
+
+func NewMappingFilter(processor MappingProcessor, classNames []string, referencedNames map[string]bool) *MappingFilter {
+	filter := MappingFilter{
+		Processor:       processor,
+		ClassNames:      classNames,
+		ReferencedNames: referencedNames,
+		// Keep the header comments.
+		keepComments: true,
+	}
+
+	return &filter
+}
+
+// Matches Returns whether the given original class name matches one of the
+// class names of the filter.
+func (filter *MappingFilter) Matches(className string) bool {
+	for _, name := range filter.ClassNames {
+		if className == name ||
+			strings.HasPrefix(className, name+".") ||
+			strings.HasPrefix(className, name+"$") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (filter *MappingFilter) ProcessClassMapping(className string, newClassName string) bool {
+	filter.obfuscatedClassName = newClassName
+	filter.keepAllMembers = filter.Matches(className)
+	filter.keepComments = filter.keepAllMembers || filter.ReferencedNames[newClassName]
+	if !filter.keepComments {
+		return false
+	}
+
+	return filter.Processor.ProcessClassMapping(className, newClassName)
+}
+
+func (filter *MappingFilter) ProcessFieldMapping(
+	className string,
+	fieldType string,
+	fieldName string,
+	newClassName string,
+	newFieldName string) {
+
+	filter.keepComments = filter.keepMember(newFieldName)
+	if filter.keepComments {
+		filter.Processor.ProcessFieldMapping(className, fieldType, fieldName, newClassName, newFieldName)
+	}
+}
+
+func (filter *MappingFilter) ProcessMethodMapping(
+	className string,
+	firstLineNumber int,
+	lastLineNumber int,
+	methodType string,
+	methodName string,
+	arguments string,
+	newClassName string,
+	newFirstLineNumber int,
+	newLastLineNumber int,
+	newMethodName string) {
+
+	// All frames of an inline group share the obfuscated method name, so
+	// they are kept or left out together.
+	filter.keepComments = filter.keepMember(newMethodName)
+	if filter.keepComments {
+		filter.Processor.ProcessMethodMapping(
+			className,
+			firstLineNumber,
+			lastLineNumber,
+			methodType,
+			methodName,
+			arguments,
+			newClassName,
+			newFirstLineNumber,
+			newLastLineNumber,
+			newMethodName,
+		)
+	}
+}
+
+func (filter *MappingFilter) ProcessComment(comment string) {
+	if commentProcessor, ok := filter.Processor.(MappingCommentProcessor); ok && filter.keepComments {
+		commentProcessor.ProcessComment(comment)
+	}
+}
+
+func (filter *MappingFilter) keepMember(newMemberName string) bool {
+	return filter.keepAllMembers ||
+		filter.ReferencedNames[filter.obfuscatedClassName+"."+newMemberName]
+}
+
+// CollectTraceNames Returns the obfuscated class names and class member
+// names, like "a.b" and "a.b.c", that the given stack traces reference, in
+// the lines that Retrace recognizes.
+func CollectTraceNames(reader io.Reader) (map[string]bool, error) {
+	patterns := []*FramePattern{
+		NewFramePattern(REGULAR_EXPRESSION, false),
+		NewFramePattern(REGULAR_EXPRESSION2, false),
+	}
+
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, pattern := range patterns {
+			frame := pattern.Parse(line)
+			if len(frame.ClassName) == 0 {
+				continue
+			}
+
+			names[frame.ClassName] = true
+			if len(frame.FieldName) > 0 {
+				names[frame.ClassName+"."+frame.FieldName] = true
+			}
+			if len(frame.MethodName) > 0 {
+				names[frame.ClassName+"."+frame.MethodName] = true
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
@@ -0,0 +1,69 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMappingFilter(t *testing.T) {
+	mapping := `# compiler: R8
+com.example.sdk.Client -> a:
+# {"id":"sourceFile","fileName":"Client.kt"}
+    int retries -> a
+    1:1:void connect():10:10 -> a
+com.example.sdk.Client$Callback -> a$a:
+    void onDone() -> a
+com.example.app.Main -> b:
+    java.lang.String name -> a
+    1:1:void com.example.app.Util.log():5:5 -> b
+    1:1:void run():20:20 -> b
+    # {"id":"com.android.tools.r8.synthesized"}
+    2:2:void stop():30:30 -> c
+com.example.app.Util -> c:
+    void log() -> a
+`
+
+	trace := `java.lang.IllegalStateException: boom
+    at b.b(Unknown Source:1)
+    at a.a(Unknown Source:1)
+`
+
+	referencedNames, err := CollectTraceNames(strings.NewReader(trace))
+	assert.NoError(t, err)
+	assert.True(t, referencedNames["b"])
+	assert.True(t, referencedNames["b.b"])
+	assert.False(t, referencedNames["b.c"])
+
+	output := bytes.NewBufferString("")
+	writer := NewMappingWriter(output)
+	filter := NewMappingFilter(writer, []string{"com.example.sdk"}, referencedNames)
+	assert.NoError(t, NewMappingReader(strings.NewReader(mapping)).Pump(filter))
+	assert.NoError(t, writer.Flush())
+
+	assert.Equal(t, `# compiler: R8
+com.example.sdk.Client -> a:
+# {"id":"sourceFile","fileName":"Client.kt"}
+    int retries -> a
+    1:1:void connect():10:10 -> a
+com.example.sdk.Client$Callback -> a$a:
+    void onDone() -> a
+com.example.app.Main -> b:
+    1:1:void com.example.app.Util.log():5:5 -> b
+    1:1:void run():20:20 -> b
+    # {"id":"com.android.tools.r8.synthesized"}
+`, output.String())
+}
+
+func TestMappingFilterMatches(t *testing.T) {
+	filter := NewMappingFilter(NewFrameRemapper(), []string{"com.example", "org.lib.Foo"}, nil)
+
+	assert.True(t, filter.Matches("com.example"))
+	assert.True(t, filter.Matches("com.example.Foo"))
+	assert.True(t, filter.Matches("com.example.sub.Bar$Baz"))
+	assert.True(t, filter.Matches("org.lib.Foo$Inner"))
+	assert.False(t, filter.Matches("com.examples.Foo"))
+	assert.False(t, filter.Matches("org.lib.FooBar"))
+}
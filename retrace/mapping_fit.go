@@ -0,0 +1,159 @@
+package retrace
+
+import (
+	"bufio"
+	"io"
+	"sort"
+)
+
+// MappingFit How well a mapping fits the obfuscated frames of a stack trace,
+// to pick the mapping of a trace without version information.
+type MappingFit struct {
+	Name string `json:"name"`
+	// Frames The number of method frames in the trace.
+	Frames int `json:"frames"`
+	// LineFrames The number of method frames with line numbers.
+	LineFrames int `json:"lineFrames"`
+	// Classes The number of frames whose class is in the mapping.
+	Classes int `json:"classes"`
+	// Methods The number of frames whose method exists on their class.
+	Methods int `json:"methods"`
+	// Lines The number of frames whose line number falls inside a mapped
+	// line range of their method.
+	Lines int `json:"lines"`
+	// Ambiguous The number of frames that remap to more than one original
+	// method.
+	Ambiguous int `json:"ambiguous"`
+}
+
+// Score returns the fraction of the checks that the frames passed, between
+// 0 and 1.
+func (fit *MappingFit) Score() float64 {
+	checks := 2*fit.Frames + fit.LineFrames
+	if checks == 0 {
+		return 0
+	}
+
+	return float64(fit.Classes+fit.Methods+fit.Lines) / float64(checks)
+}
+
+// BetterThan returns whether this fit is better than the given one: it
+// passes more checks, or as many with fewer ambiguous frames.
+func (fit *MappingFit) BetterThan(other *MappingFit) bool {
+	passed := fit.Classes + fit.Methods + fit.Lines
+	otherPassed := other.Classes + other.Methods + other.Lines
+	if passed != otherPassed {
+		return passed > otherPassed
+	}
+
+	return fit.Ambiguous < other.Ambiguous
+}
+
+// CollectTraceFrames Returns the method frames of the given stack traces, in
+// the lines that Retrace recognizes.
+func CollectTraceFrames(reader io.Reader) ([]FrameInfo, error) {
+	pattern := NewFramePattern(REGULAR_EXPRESSION, false)
+
+	var frames []FrameInfo
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		frame := pattern.Parse(scanner.Text())
+		if len(frame.ClassName) > 0 && len(frame.MethodName) > 0 {
+			frames = append(frames, frame)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return frames, nil
+}
+
+// FitMapping Checks the given obfuscated frames against the given mapping,
+// like a FrameRemapper or a MappingIndex: whether their classes are in the
+// mapping, whether their methods exist on those classes, and whether their
+// line numbers fall inside mapped ranges. Remappers of other packages can't
+// be checked, so none of the frames fit them.
+func FitMapping(name string, remapper Remapper, frames []FrameInfo) MappingFit {
+	fit := MappingFit{Name: name}
+	lookup, _ := remapper.(mappingLookup)
+
+	for index := range frames {
+		frame := &frames[index]
+
+		fit.Frames++
+		if frame.LineNumber > 0 {
+			fit.LineFrames++
+		}
+
+		if lookup == nil || !lookup.hasClass(frame.ClassName) {
+			continue
+		}
+		fit.Classes++
+
+		originalClassName := lookup.GetOriginalClassName(frame.ClassName)
+		if len(lookup.methodInfos(originalClassName, frame.MethodName, 0)) == 0 {
+			continue
+		}
+		fit.Methods++
+
+		alternatives := 0
+		inRange := false
+		var first MethodInfo
+		found := false
+		for _, methodInfo := range lookup.methodInfos(originalClassName, frame.MethodName, frame.LineNumber) {
+			if !methodInfo.Matches(frame.LineNumber, "", "") {
+				continue
+			}
+
+			// A method without line numbers matches any line, so it
+			// doesn't tell the mappings apart.
+			if frame.LineNumber > 0 && methodInfo.ObfuscatedLastLineNumber != 0 {
+				inRange = true
+			}
+
+			// The methods that were inlined into a method share its
+			// obfuscated line range, so they aren't alternatives.
+			if !found {
+				first = methodInfo
+				found = true
+			} else if first.ObfuscatedLastLineNumber == 0 ||
+				first.ObfuscatedFirstLineNumber != methodInfo.ObfuscatedFirstLineNumber ||
+				first.ObfuscatedLastLineNumber != methodInfo.ObfuscatedLastLineNumber {
+				alternatives++
+			}
+		}
+
+		if inRange {
+			fit.Lines++
+		}
+		if alternatives > 0 {
+			fit.Ambiguous++
+		}
+	}
+
+	return fit
+}
+
+// RankMappingFits Sorts the given fits from the best fitting mapping to the
+// worst one, keeping the order of mappings that fit equally well, and returns
+// the original indices of the sorted fits, to sort what belongs to them in
+// the same way.
+func RankMappingFits(fits []MappingFit) []int {
+	order := make([]int, len(fits))
+	for index := range order {
+		order[index] = index
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return fits[order[i]].BetterThan(&fits[order[j]])
+	})
+
+	rankedFits := make([]MappingFit, len(fits))
+	for rank, index := range order {
+		rankedFits[rank] = fits[index]
+	}
+	copy(fits, rankedFits)
+
+	return order
+}
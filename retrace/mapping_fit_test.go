@@ -0,0 +1,64 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const fitTrace = `java.lang.IllegalStateException: boom
+	at a.a(SourceFile:2)
+	at a.b(SourceFile:12)
+	at b.a(SourceFile:5)
+	at java.lang.Thread.run(Thread.java:764)
+`
+
+func TestMappingFit(t *testing.T) {
+	frames, err := CollectTraceFrames(strings.NewReader(fitTrace))
+	assert.NoError(t, err)
+	assert.Len(t, frames, 4)
+
+	readRemapper := func(mapping string) *FrameRemapper {
+		remapper := NewFrameRemapper()
+		assert.NoError(t, NewMappingReader(strings.NewReader(mapping)).Pump(remapper))
+		return remapper
+	}
+
+	// The mapping of the trace.
+	matching := readRemapper(`com.example.Foo -> a:
+    1:3:void inlined():20:22 -> a
+    1:3:void foo():10:12 -> a
+    10:14:void bar():30:34 -> b
+com.example.Bar -> b:
+    4:6:void baz():40:42 -> a
+`)
+	// The mapping of another version, with other line ranges.
+	other := readRemapper(`com.example.Foo -> a:
+    5:7:void foo():10:12 -> a
+    void bar() -> b
+    void qux() -> b
+com.example.Bar -> c:
+`)
+
+	fits := []MappingFit{
+		FitMapping("other", other, frames),
+		FitMapping("matching", matching, frames),
+	}
+
+	assert.Equal(t, MappingFit{Name: "other", Frames: 4, LineFrames: 4, Classes: 2, Methods: 2, Lines: 0, Ambiguous: 1}, fits[0])
+	assert.Equal(t, MappingFit{Name: "matching", Frames: 4, LineFrames: 4, Classes: 3, Methods: 3, Lines: 3, Ambiguous: 0}, fits[1])
+	assert.InDelta(t, 9.0/12.0, fits[1].Score(), 1e-9)
+
+	assert.Equal(t, []int{1, 0}, RankMappingFits(fits))
+	assert.Equal(t, "matching", fits[0].Name)
+	assert.Equal(t, "other", fits[1].Name)
+
+	// A mapping index fits like the mapping.
+	buffer := bytes.NewBufferString("")
+	assert.NoError(t, WriteMappingIndex(matching, buffer))
+	index, err := ReadMappingIndex(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, FitMapping("matching", matching, frames), FitMapping("matching", index, frames))
+}
@@ -0,0 +1,115 @@
+package retrace
+
+import (
+	"fmt"
+	"io"
+)
+
+// MappingPump A source of mappings, like MappingReader, that passes them to
+// a MappingProcessor.
+type MappingPump interface {
+	Pump(processor MappingProcessor) error
+}
+
+// MappingFormatWriter A MappingProcessor that writes the mappings it
+// receives, like MappingWriter.
+type MappingFormatWriter interface {
+	MappingProcessor
+	// Flush Writes any buffered mappings, and returns the first error that
+	// occurred while writing.
+	Flush() error
+}
+
+// LossReporter This interface can optionally be implemented by a MappingPump
+// or a MappingFormatWriter that can't express everything of a mapping, to
+// report what it left out.
+type LossReporter interface {
+	// Losses Returns what was left out, like "parameter names (12)".
+	Losses() []string
+}
+
+// MappingFormat A mapping file format, which mappings can be converted from
+// and to through the MappingProcessor interface.
+type MappingFormat struct {
+	Name      string
+	NewPump   func(reader io.Reader) MappingPump
+	NewWriter func(writer io.Writer) MappingFormatWriter
+}
+
+// MappingFormats The supported mapping formats, by name.
+var MappingFormats = map[string]*MappingFormat{
+	"proguard": {
+		Name:      "proguard",
+		NewPump:   func(reader io.Reader) MappingPump { return NewMappingReader(reader) },
+		NewWriter: func(writer io.Writer) MappingFormatWriter { return NewMappingWriter(writer) },
+	},
+	"tiny2": {
+		Name:      "tiny2",
+		NewPump:   func(reader io.Reader) MappingPump { return NewTinyMappingReader(reader) },
+		NewWriter: func(writer io.Writer) MappingFormatWriter { return NewTinyMappingWriter(writer) },
+	},
+}
+
+// MappingFormatNames returns the names of the supported mapping formats.
+func MappingFormatNames() []string {
+	return sortedKeys(MappingFormats)
+}
+
+// MappingLosses What a conversion of a mapping left out.
+type MappingLosses struct {
+	// Read What the reader of the source format didn't pass on, like
+	// parameter names.
+	Read []string
+	// Written What the target format can't express, like line numbers.
+	Written []string
+}
+
+// ConvertMapping Converts a mapping from one format to another, and returns
+// what the reader and the writer left out.
+func ConvertMapping(from string, to string, reader io.Reader, writer io.Writer) (MappingLosses, error) {
+	var losses MappingLosses
+
+	fromFormat, ok := MappingFormats[from]
+	if !ok {
+		return losses, fmt.Errorf("unknown mapping format %s", from)
+	}
+
+	toFormat, ok := MappingFormats[to]
+	if !ok {
+		return losses, fmt.Errorf("unknown mapping format %s", to)
+	}
+
+	pump := fromFormat.NewPump(reader)
+	formatWriter := toFormat.NewWriter(writer)
+	if err := pump.Pump(formatWriter); err != nil {
+		return losses, err
+	}
+
+	if err := formatWriter.Flush(); err != nil {
+		return losses, err
+	}
+
+	if lossReporter, ok := pump.(LossReporter); ok {
+		losses.Read = lossReporter.Losses()
+	}
+	if lossReporter, ok := formatWriter.(LossReporter); ok {
+		losses.Written = lossReporter.Losses()
+	}
+
+	return losses, nil
+}
+
+// lossCounter Counts the things that a format can't express.
+type lossCounter map[string]int
+
+func (counter lossCounter) add(what string) {
+	counter[what]++
+}
+
+func (counter lossCounter) Losses() []string {
+	var losses []string
+	for _, what := range sortedKeys(counter) {
+		losses = append(losses, fmt.Sprintf("%s (%d)", what, counter[what]))
+	}
+	return losses
+}
@@ -0,0 +1,150 @@
+package retrace
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// The DNS namespace of RFC 4122, 6ba7b810-9dad-11d1-80b4-00c04fd430c8.
+var uuidNamespaceDNS = [16]byte{
+	0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1,
+	0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+}
+
+// The namespace of the UUIDs of ProGuard mappings in Sentry.
+var proguardUUIDNamespace = uuidV5(newUUIDV5Hash(uuidNamespaceDNS, []byte("guardsquare.com")))
+
+// MappingIdentity The identity of a mapping file.
+type MappingIdentity struct {
+	// MapID The pg_map_id of the header of the mapping, if any.
+	MapID string `json:"mapId,omitempty"`
+	// MapHash The pg_map_hash of the header of the mapping, if any, like
+	// "SHA-256 0123abcd...".
+	MapHash string `json:"mapHash,omitempty"`
+	// ComputedHash The SHA-256 hash of the content of the mapping after its
+	// header comments, in the same form as MapHash.
+	ComputedHash string `json:"computedHash"`
+	// DebugID The UUIDv5 with which Sentry identifies the mapping file.
+	DebugID string `json:"debugId"`
+}
+
+// HashVerified returns whether the mapping has a pg_map_hash that matches
+// its content.
+func (identity *MappingIdentity) HashVerified() bool {
+	return len(identity.MapHash) > 0 && strings.EqualFold(identity.MapHash, identity.ComputedHash)
+}
+
+// Verify Returns an error if the mapping has a pg_map_hash that doesn't
+// match its content, for example because it was edited.
+func (identity *MappingIdentity) Verify() error {
+	if len(identity.MapHash) > 0 && !identity.HashVerified() {
+		return fmt.Errorf("pg_map_hash %s doesn't match the content of the mapping, which has %s", identity.MapHash, identity.ComputedHash)
+	}
+
+	return nil
+}
+
+// ComputeMappingIdentity Reads the given mapping file and returns its
+// identity. R8 computes the pg_map_hash over the content after the comment
+// lines at the top of the mapping file, and Sentry computes the debug ID over
+// the entire file.
+func ComputeMappingIdentity(reader io.Reader) (*MappingIdentity, error) {
+	identity := MappingIdentity{}
+
+	contentHash := sha256.New()
+	fileHash := newUUIDV5Hash(proguardUUIDNamespace, nil)
+
+	inHeader := true
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, err := bufReader.ReadString('\n')
+		if len(line) > 0 {
+			fileHash.Write([]byte(line))
+
+			trimmedLine := strings.TrimSpace(line)
+			if inHeader && strings.HasPrefix(trimmedLine, "#") {
+				key, value := parseHeaderComment(strings.TrimSpace(trimmedLine[1:]))
+				switch key {
+				case "pg_map_id":
+					identity.MapID = value
+				case "pg_map_hash":
+					identity.MapHash = value
+				}
+			} else {
+				inHeader = false
+				contentHash.Write([]byte(line))
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	identity.ComputedHash = "SHA-256 " + hex.EncodeToString(contentHash.Sum(nil))
+	identity.DebugID = formatUUID(uuidV5(fileHash))
+
+	return &identity, nil
+}
+
+// MappingMapID returns the pg_map_id and pg_map_hash of the given header
+// comments of a mapping, as FrameRemapper.HeaderComments and
+// MappingIndex.HeaderComments return them, or empty strings.
+func MappingMapID(headerComments []string) (mapID string, mapHash string) {
+	for _, comment := range headerComments {
+		key, value := parseHeaderComment(comment)
+		switch key {
+		case "pg_map_id":
+			mapID = value
+		case "pg_map_hash":
+			mapHash = value
+		}
+	}
+
+	return mapID, mapHash
+}
+
+// parseHeaderComment Splits a header comment like "pg_map_id: 1a2b3c4" into
+// its key and value.
+func parseHeaderComment(comment string) (string, string) {
+	colonIndex := strings.Index(comment, ":")
+	if colonIndex < 0 {
+		return "", ""
+	}
+
+	return strings.TrimSpace(comment[:colonIndex]), strings.TrimSpace(comment[colonIndex+1:])
+}
+
+// newUUIDV5Hash Returns a SHA-1 hash of the given namespace, to which the
+// name of a UUIDv5 can be written.
+func newUUIDV5Hash(namespace [16]byte, name []byte) hash.Hash {
+	sha := sha1.New()
+	sha.Write(namespace[:])
+	sha.Write(name)
+	return sha
+}
+
+// uuidV5 Returns the UUIDv5 of the given hash of a namespace and a name.
+func uuidV5(sha hash.Hash) [16]byte {
+	var uuid [16]byte
+	copy(uuid[:], sha.Sum(nil))
+
+	// Set the version 5 and the RFC 4122 variant.
+	uuid[6] = uuid[6]&0x0f | 0x50
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return uuid
+}
+
+func formatUUID(uuid [16]byte) string {
+	encoded := hex.EncodeToString(uuid[:])
+	return encoded[0:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:32]
+}
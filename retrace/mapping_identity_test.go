@@ -0,0 +1,49 @@
+package retrace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const identityMappingData = `# compiler: R8
+# pg_map_id: fdcbdac
+# pg_map_hash: SHA-256 fdcbdac484c5e7e11516c5422067840010931101e16be9e3b4647565fa29f391
+com.example.Foo -> a:
+    void bar() -> a
+`
+
+func TestComputeMappingIdentity(t *testing.T) {
+	identity, err := ComputeMappingIdentity(strings.NewReader(identityMappingData))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "fdcbdac", identity.MapID)
+	assert.Equal(t, "SHA-256 fdcbdac484c5e7e11516c5422067840010931101e16be9e3b4647565fa29f391", identity.ComputedHash)
+	assert.True(t, identity.HashVerified())
+	assert.NoError(t, identity.Verify())
+	assert.Equal(t, "3a624463-b5af-532d-9dc8-d6108df93afd", identity.DebugID)
+	assert.Equal(t, "4f44f30f-24be-53d0-bab6-f47c7120ad6c", formatUUID(proguardUUIDNamespace))
+
+	// An edited mapping doesn't match its hash anymore.
+	identity, err = ComputeMappingIdentity(strings.NewReader(identityMappingData + "    void baz() -> b\n"))
+	assert.NoError(t, err)
+	assert.False(t, identity.HashVerified())
+	assert.Error(t, identity.Verify())
+
+	// A mapping without a hash can't be verified, but isn't wrong either.
+	identity, err = ComputeMappingIdentity(strings.NewReader("com.example.Foo -> a:\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", identity.MapID)
+	assert.False(t, identity.HashVerified())
+	assert.NoError(t, identity.Verify())
+}
+
+func TestMappingMapID(t *testing.T) {
+	remapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(identityMappingData)).Pump(remapper))
+
+	mapID, mapHash := MappingMapID(remapper.HeaderComments)
+	assert.Equal(t, "fdcbdac", mapID)
+	assert.Equal(t, "SHA-256 fdcbdac484c5e7e11516c5422067840010931101e16be9e3b4647565fa29f391", mapHash)
+}
@@ -0,0 +1,672 @@
+package retrace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A mapping index is a compact binary form of a mapping, which can be
+// written once per mapping and then retraced with, without parsing the
+// mapping and building the maps of a FrameRemapper. All numbers are
+// little-endian uint32 values, and all names are IDs in a sorted string
+// table, so every name is stored only once:
+//
+//	magic "GRMI", version
+//	string count, string end offsets, string bytes
+//	header comment count, comment string IDs
+//	class count, {obfuscated name ID, original name ID}, sorted by obfuscated name
+//	original class count, {original name ID, member block offset}, sorted by original name
+//	member blocks:
+//	    comment count, comment string IDs
+//	    field count, {obfuscated name ID, class ID, type ID, name ID}, sorted by obfuscated name
+//	    method count, {obfuscated name ID, obfuscated first line, obfuscated last line,
+//	                   class ID, first line, last line, type ID, name ID, arguments ID},
+//	                  sorted by obfuscated name, in mapping file order otherwise
+//	    synthesized member count, obfuscated name IDs of the class members that
+//	                              R8 marked as synthesized, sorted
+//
+// The string IDs are in the order of the strings, so the tables that are
+// sorted by name are sorted by ID as well.
+
+const mappingIndexMagic = "GRMI"
+
+// MappingIndexVersion The version of the mapping index format that this
+// package writes and reads.
+const MappingIndexVersion = 1
+
+const (
+	classEntrySize         = 2 * 4
+	originalClassEntrySize = 2 * 4
+	fieldRecordSize        = 4 * 4
+	methodRecordSize       = 9 * 4
+)
+
+// WriteMappingIndex Writes the mappings of the given remapper as a mapping
+// index.
+func WriteMappingIndex(remapper *FrameRemapper, writer io.Writer) error {
+	// Collect the original classes, which own the member blocks.
+	originalClassNameSet := make(map[string]bool)
+	for className := range remapper.ClassFieldMap {
+		originalClassNameSet[className] = true
+	}
+	for className := range remapper.ClassMethodMap {
+		originalClassNameSet[className] = true
+	}
+	for className := range remapper.ClassComments {
+		originalClassNameSet[className] = true
+	}
+	// The index keeps only whether class members are synthesized, rather
+	// than their comments.
+	synthesizedMemberSets := make(map[string]map[string]bool)
+	for _, classComments := range []map[string]ObfuscatedNameCommentsMap{remapper.ClassFieldComments, remapper.ClassMethodComments} {
+		for className, commentsMap := range classComments {
+			for newMemberName, comments := range commentsMap {
+				if containsSynthesizedComment(comments) {
+					if synthesizedMemberSets[className] == nil {
+						synthesizedMemberSets[className] = make(map[string]bool)
+					}
+					synthesizedMemberSets[className][newMemberName] = true
+					originalClassNameSet[className] = true
+				}
+			}
+		}
+	}
+	synthesizedMembers := make(map[string][]string, len(synthesizedMemberSets))
+	for className, newMemberNameSet := range synthesizedMemberSets {
+		synthesizedMembers[className] = sortedKeys(newMemberNameSet)
+	}
+	originalClassNames := sortedKeys(originalClassNameSet)
+
+	// Collect and sort all strings.
+	stringSet := make(map[string]bool)
+	for _, comment := range remapper.HeaderComments {
+		stringSet[comment] = true
+	}
+	for newClassName, className := range remapper.ClassMap {
+		stringSet[newClassName] = true
+		stringSet[className] = true
+	}
+	for _, className := range originalClassNames {
+		stringSet[className] = true
+		for _, comment := range remapper.ClassComments[className] {
+			stringSet[comment] = true
+		}
+		for newFieldName, fieldSet := range remapper.ClassFieldMap[className] {
+			stringSet[newFieldName] = true
+			for _, fieldInfo := range fieldSet.Values() {
+				stringSet[fieldInfo.OriginalClassName] = true
+				stringSet[fieldInfo.OriginalType] = true
+				stringSet[fieldInfo.OriginalName] = true
+			}
+		}
+		for newMethodName, methodSet := range remapper.ClassMethodMap[className] {
+			stringSet[newMethodName] = true
+			for _, methodInfo := range methodSet.Values() {
+				stringSet[methodInfo.OriginalClassName] = true
+				stringSet[methodInfo.OriginalType] = true
+				stringSet[methodInfo.OriginalName] = true
+				stringSet[methodInfo.OriginalArguments] = true
+			}
+		}
+		for _, newMemberName := range synthesizedMembers[className] {
+			stringSet[newMemberName] = true
+		}
+	}
+	strs := sortedKeys(stringSet)
+	stringIDs := make(map[string]uint32, len(strs))
+	for id, str := range strs {
+		stringIDs[str] = uint32(id)
+	}
+
+	indexWriter := &mappingIndexWriter{writer: bufio.NewWriter(writer)}
+
+	// Magic and version.
+	indexWriter.writeString(mappingIndexMagic)
+	indexWriter.writeUint32(MappingIndexVersion)
+
+	// String table.
+	indexWriter.writeUint32(uint32(len(strs)))
+	stringEnd := 0
+	for _, str := range strs {
+		stringEnd += len(str)
+		indexWriter.writeUint32(uint32(stringEnd))
+	}
+	for _, str := range strs {
+		indexWriter.writeString(str)
+	}
+
+	writeStringIDs := func(values []string) {
+		indexWriter.writeUint32(uint32(len(values)))
+		for _, value := range values {
+			indexWriter.writeUint32(stringIDs[value])
+		}
+	}
+
+	// Header comments.
+	writeStringIDs(remapper.HeaderComments)
+
+	// Class table.
+	newClassNames := sortedKeys(remapper.ClassMap)
+	indexWriter.writeUint32(uint32(len(newClassNames)))
+	for _, newClassName := range newClassNames {
+		indexWriter.writeUint32(stringIDs[newClassName])
+		indexWriter.writeUint32(stringIDs[remapper.ClassMap[newClassName]])
+	}
+
+	// Original class table, with the offsets of the member blocks that
+	// follow it.
+	indexWriter.writeUint32(uint32(len(originalClassNames)))
+	blockOffset := indexWriter.offset + len(originalClassNames)*originalClassEntrySize
+	for _, className := range originalClassNames {
+		indexWriter.writeUint32(stringIDs[className])
+		indexWriter.writeUint32(uint32(blockOffset))
+
+		blockOffset += 3*4 + len(remapper.ClassComments[className])*4
+		for _, fieldSet := range remapper.ClassFieldMap[className] {
+			blockOffset += fieldSet.Size() * fieldRecordSize
+		}
+		for _, methodSet := range remapper.ClassMethodMap[className] {
+			blockOffset += methodSet.Size() * methodRecordSize
+		}
+		blockOffset += 4 + len(synthesizedMembers[className])*4
+	}
+
+	// Member blocks.
+	for _, className := range originalClassNames {
+		writeStringIDs(remapper.ClassComments[className])
+
+		fieldMap := remapper.ClassFieldMap[className]
+		var fieldCount int
+		for _, fieldSet := range fieldMap {
+			fieldCount += fieldSet.Size()
+		}
+		indexWriter.writeUint32(uint32(fieldCount))
+		for _, newFieldName := range sortedKeys(fieldMap) {
+			for _, fieldInfo := range fieldMap[newFieldName].Values() {
+				indexWriter.writeUint32(stringIDs[newFieldName])
+				indexWriter.writeUint32(stringIDs[fieldInfo.OriginalClassName])
+				indexWriter.writeUint32(stringIDs[fieldInfo.OriginalType])
+				indexWriter.writeUint32(stringIDs[fieldInfo.OriginalName])
+			}
+		}
+
+		methodMap := remapper.ClassMethodMap[className]
+		var methodCount int
+		for _, methodSet := range methodMap {
+			methodCount += methodSet.Size()
+		}
+		indexWriter.writeUint32(uint32(methodCount))
+		for _, newMethodName := range sortedKeys(methodMap) {
+			for _, methodInfo := range methodMap[newMethodName].Values() {
+				indexWriter.writeUint32(stringIDs[newMethodName])
+				indexWriter.writeUint32(uint32(methodInfo.ObfuscatedFirstLineNumber))
+				indexWriter.writeUint32(uint32(methodInfo.ObfuscatedLastLineNumber))
+				indexWriter.writeUint32(stringIDs[methodInfo.OriginalClassName])
+				indexWriter.writeUint32(uint32(methodInfo.OriginalFirstLineNumber))
+				indexWriter.writeUint32(uint32(methodInfo.OriginalLastLineNumber))
+				indexWriter.writeUint32(stringIDs[methodInfo.OriginalType])
+				indexWriter.writeUint32(stringIDs[methodInfo.OriginalName])
+				indexWriter.writeUint32(stringIDs[methodInfo.OriginalArguments])
+			}
+		}
+
+		writeStringIDs(synthesizedMembers[className])
+	}
+
+	if indexWriter.err != nil {
+		return indexWriter.err
+	}
+
+	return indexWriter.writer.Flush()
+}
+
+// mappingIndexWriter Writes the parts of a mapping index, and remembers the
+// first error and the offset.
+type mappingIndexWriter struct {
+	writer *bufio.Writer
+	offset int
+	err    error
+	buffer [4]byte
+}
+
+func (w *mappingIndexWriter) writeUint32(value uint32) {
+	binary.LittleEndian.PutUint32(w.buffer[:], value)
+	w.write(w.buffer[:])
+}
+
+func (w *mappingIndexWriter) writeString(value string) {
+	if w.err == nil {
+		_, w.err = w.writer.WriteString(value)
+	}
+	w.offset += len(value)
+}
+
+func (w *mappingIndexWriter) write(data []byte) {
+	if w.err == nil {
+		_, w.err = w.writer.Write(data)
+	}
+	w.offset += len(data)
+}
+
+// IsMappingIndex returns whether the given data starts like a mapping index
+// rather than like a mapping file.
+func IsMappingIndex(data []byte) bool {
+	return len(data) >= len(mappingIndexMagic) && string(data[:len(mappingIndexMagic)]) == mappingIndexMagic
+}
+
+// MappingIndex A Remapper that looks up the mappings straight from the data
+// of a mapping index, without decoding it up front. Only the classes and
+// class members that GetOriginalClassName and Transform ask for are decoded,
+// so an index that is opened with OpenMappingIndex costs little memory until
+// it is used, and many of them can be kept open at the same time.
+type MappingIndex struct {
+	data []byte
+	// Releases the data, if it is mapped into memory.
+	unmap func() error
+
+	stringCount   int
+	stringOffsets int
+	stringData    int
+
+	headerComments int
+
+	classCount int
+	classTable int
+
+	originalClassCount int
+	originalClassTable int
+}
+
+// NewMappingIndex Returns a MappingIndex for the given data of a mapping
+// index, which must not change while the index is used.
+func NewMappingIndex(data []byte) (*MappingIndex, error) {
+	if !IsMappingIndex(data) {
+		return nil, errors.New("not a mapping index")
+	}
+
+	index := MappingIndex{data: data}
+	offset := len(mappingIndexMagic)
+
+	// readCount Reads a count of entries of the given size at the
+	// offset, and skips past them.
+	readCount := func(entrySize int) (int, int, error) {
+		if offset+4 > len(data) {
+			return 0, 0, errors.New("truncated mapping index")
+		}
+		count := int(binary.LittleEndian.Uint32(data[offset:]))
+		start := offset + 4
+		if count > (len(data)-start)/entrySize {
+			return 0, 0, errors.New("truncated mapping index")
+		}
+		offset = start + count*entrySize
+		return count, start, nil
+	}
+
+	if offset+4 > len(data) {
+		return nil, errors.New("truncated mapping index")
+	}
+	version := binary.LittleEndian.Uint32(data[offset:])
+	if version != MappingIndexVersion {
+		return nil, fmt.Errorf("unsupported mapping index version %d, expected %d", version, MappingIndexVersion)
+	}
+	offset += 4
+
+	var err error
+	if index.stringCount, index.stringOffsets, err = readCount(4); err != nil {
+		return nil, err
+	}
+	index.stringData = offset
+	if index.stringCount > 0 {
+		offset += int(index.uint32At(index.stringOffsets + (index.stringCount-1)*4))
+		if offset > len(data) {
+			return nil, errors.New("truncated mapping index")
+		}
+	}
+
+	index.headerComments = offset
+	if _, _, err = readCount(4); err != nil {
+		return nil, err
+	}
+
+	if index.classCount, index.classTable, err = readCount(classEntrySize); err != nil {
+		return nil, err
+	}
+	if index.originalClassCount, index.originalClassTable, err = readCount(originalClassEntrySize); err != nil {
+		return nil, err
+	}
+
+	return &index, nil
+}
+
+// ReadMappingIndex Reads a mapping index that was written by
+// WriteMappingIndex.
+func ReadMappingIndex(reader io.Reader) (*MappingIndex, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMappingIndex(data)
+}
+
+// OpenMappingIndex Opens the given mapping index file by mapping it into
+// memory read-only, where the platform supports it. The index must be closed
+// when it is no longer used.
+func OpenMappingIndex(filePath string) (*MappingIndex, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, unmap, err := mapFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := NewMappingIndex(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+
+	index.unmap = unmap
+	return index, nil
+}
+
+// Close Releases the data of the index, if it was opened with
+// OpenMappingIndex. The index finds no mappings after it is closed.
+func (index *MappingIndex) Close() error {
+	unmap := index.unmap
+	*index = MappingIndex{}
+	if unmap == nil {
+		return nil
+	}
+
+	return unmap()
+}
+
+// ClassCount returns the number of class mappings in the index.
+func (index *MappingIndex) ClassCount() int {
+	return index.classCount
+}
+
+// HeaderComments returns the comments before the first class mapping.
+func (index *MappingIndex) HeaderComments() []string {
+	return index.stringList(index.headerComments)
+}
+
+// ClassComments returns the comments following the mapping of the given
+// original class.
+func (index *MappingIndex) ClassComments(originalClassName string) []string {
+	block, ok := index.memberBlock(originalClassName)
+	if !ok {
+		return nil
+	}
+
+	return index.stringList(block)
+}
+
+// SourceFileName Returns the source file of the given original class in R8's
+// metadata, or an empty string.
+func (index *MappingIndex) SourceFileName(originalClassName string) string {
+	return sourceFileFromComments(index.ClassComments(originalClassName))
+}
+
+// IsSynthesized Returns whether R8 marked the given original class, or the
+// class member with the given obfuscated name in it, as synthesized.
+func (index *MappingIndex) IsSynthesized(originalClassName string, obfuscatedMemberName string) bool {
+	if containsSynthesizedComment(index.ClassComments(originalClassName)) {
+		return true
+	}
+	if len(obfuscatedMemberName) == 0 {
+		return false
+	}
+
+	block, ok := index.memberBlock(originalClassName)
+	if !ok {
+		return false
+	}
+	nameID, ok := index.stringID(obfuscatedMemberName)
+	if !ok {
+		return false
+	}
+
+	// Skip the comments, the fields and the methods.
+	table, ok := index.table(block, 4)
+	if !ok {
+		return false
+	}
+	if table, ok = index.table(table+index.tableSize(table, 4), fieldRecordSize); !ok {
+		return false
+	}
+	if table, ok = index.table(table+index.tableSize(table, fieldRecordSize), methodRecordSize); !ok {
+		return false
+	}
+	if table, ok = index.table(table+index.tableSize(table, methodRecordSize), 4); !ok {
+		return false
+	}
+
+	_, found := index.findEntry(table+4, int(index.uint32At(table)), 4, nameID)
+	return found
+}
+
+func (index *MappingIndex) GetOriginalClassName(obfuscatedClassName string) string {
+	if id, ok := index.stringID(obfuscatedClassName); ok {
+		entry, found := index.findEntry(index.classTable, index.classCount, classEntrySize, id)
+		if found {
+			return index.string(index.uint32At(entry + 4))
+		}
+	}
+
+	return obfuscatedClassName
+}
+
+func (index *MappingIndex) Transform(obfuscatedFrame *FrameInfo) []FrameInfo {
+	return transformFrame(index, obfuscatedFrame)
+}
+
+func (index *MappingIndex) hasClass(obfuscatedClassName string) bool {
+	if id, ok := index.stringID(obfuscatedClassName); ok {
+		_, found := index.findEntry(index.classTable, index.classCount, classEntrySize, id)
+		return found
+	}
+
+	return false
+}
+
+func (index *MappingIndex) fieldInfos(originalClassName string, obfuscatedFieldName string) []FieldInfo {
+	records, count, ok := index.memberRecords(originalClassName, obfuscatedFieldName, false)
+	if !ok {
+		return nil
+	}
+
+	var fieldInfos []FieldInfo
+	for record := records; record < records+count*fieldRecordSize; record += fieldRecordSize {
+		fieldInfos = append(fieldInfos, FieldInfo{
+			OriginalClassName: index.string(index.uint32At(record + 4)),
+			OriginalType:      index.string(index.uint32At(record + 8)),
+			OriginalName:      index.string(index.uint32At(record + 12)),
+		})
+	}
+
+	return fieldInfos
+}
+
+func (index *MappingIndex) methodInfos(originalClassName string, obfuscatedMethodName string, obfuscatedLineNumber int) []MethodInfo {
+	records, count, ok := index.memberRecords(originalClassName, obfuscatedMethodName, true)
+	if !ok {
+		return nil
+	}
+
+	var methodInfos []MethodInfo
+	for record := records; record < records+count*methodRecordSize; record += methodRecordSize {
+		methodInfos = append(methodInfos, MethodInfo{
+			ObfuscatedFirstLineNumber: int(index.uint32At(record + 4)),
+			ObfuscatedLastLineNumber:  int(index.uint32At(record + 8)),
+			OriginalClassName:         index.string(index.uint32At(record + 12)),
+			OriginalFirstLineNumber:   int(index.uint32At(record + 16)),
+			OriginalLastLineNumber:    int(index.uint32At(record + 20)),
+			OriginalType:              index.string(index.uint32At(record + 24)),
+			OriginalName:              index.string(index.uint32At(record + 28)),
+			OriginalArguments:         index.string(index.uint32At(record + 32)),
+		})
+	}
+
+	return methodInfos
+}
+
+// memberRecords returns the offset and the number of the field or method
+// records of the given original class with the given obfuscated name.
+func (index *MappingIndex) memberRecords(originalClassName string, obfuscatedMemberName string, methods bool) (int, int, bool) {
+	block, ok := index.memberBlock(originalClassName)
+	if !ok {
+		return 0, 0, false
+	}
+
+	nameID, ok := index.stringID(obfuscatedMemberName)
+	if !ok {
+		return 0, 0, false
+	}
+
+	// Skip the comments, and the fields if we need the methods.
+	table, ok := index.table(block, 4)
+	if !ok {
+		return 0, 0, false
+	}
+	recordSize := fieldRecordSize
+	if table, ok = index.table(table+index.tableSize(table, 4), fieldRecordSize); !ok {
+		return 0, 0, false
+	}
+	if methods {
+		recordSize = methodRecordSize
+		if table, ok = index.table(table+index.tableSize(table, fieldRecordSize), methodRecordSize); !ok {
+			return 0, 0, false
+		}
+	}
+
+	count := int(index.uint32At(table))
+	records := table + 4
+
+	// Find the range of records with the name.
+	first := sort.Search(count, func(i int) bool {
+		return index.uint32At(records+i*recordSize) >= nameID
+	})
+	last := first
+	for last < count && index.uint32At(records+last*recordSize) == nameID {
+		last++
+	}
+
+	return records + first*recordSize, last - first, last > first
+}
+
+// memberBlock returns the offset of the member block of the given original
+// class.
+func (index *MappingIndex) memberBlock(originalClassName string) (int, bool) {
+	id, ok := index.stringID(originalClassName)
+	if !ok {
+		return 0, false
+	}
+
+	entry, ok := index.findEntry(index.originalClassTable, index.originalClassCount, originalClassEntrySize, id)
+	if !ok {
+		return 0, false
+	}
+
+	return int(index.uint32At(entry + 4)), true
+}
+
+// table checks that the table with a count at the given offset and entries
+// of the given size fits in the data.
+func (index *MappingIndex) table(offset int, entrySize int) (int, bool) {
+	if offset < 0 || offset+4 > len(index.data) {
+		return 0, false
+	}
+
+	count := int(index.uint32At(offset))
+	return offset, count <= (len(index.data)-offset-4)/entrySize
+}
+
+// tableSize returns the size of the table at the given offset, including its
+// count.
+func (index *MappingIndex) tableSize(offset int, entrySize int) int {
+	return 4 + int(index.uint32At(offset))*entrySize
+}
+
+// findEntry returns the offset of the entry with the given ID in the given
+// table of entries that start with their sorted IDs.
+func (index *MappingIndex) findEntry(table int, count int, entrySize int, id uint32) (int, bool) {
+	i := sort.Search(count, func(i int) bool {
+		return index.uint32At(table+i*entrySize) >= id
+	})
+	if i < count && index.uint32At(table+i*entrySize) == id {
+		return table + i*entrySize, true
+	}
+
+	return 0, false
+}
+
+// stringList returns the strings of the table of string IDs at the given
+// offset.
+func (index *MappingIndex) stringList(offset int) []string {
+	table, ok := index.table(offset, 4)
+	if !ok {
+		return nil
+	}
+
+	var strs []string
+	count := int(index.uint32At(table))
+	for i := 0; i < count; i++ {
+		strs = append(strs, index.string(index.uint32At(table+4+i*4)))
+	}
+	return strs
+}
+
+// stringID returns the ID of the given string, if the index contains it.
+func (index *MappingIndex) stringID(str string) (uint32, bool) {
+	i := sort.Search(index.stringCount, func(i int) bool {
+		return strings.Compare(string(index.stringBytes(uint32(i))), str) >= 0
+	})
+	if i < index.stringCount && string(index.stringBytes(uint32(i))) == str {
+		return uint32(i), true
+	}
+
+	return 0, false
+}
+
+func (index *MappingIndex) string(id uint32) string {
+	return string(index.stringBytes(id))
+}
+
+func (index *MappingIndex) stringBytes(id uint32) []byte {
+	if int(id) >= index.stringCount {
+		return nil
+	}
+
+	start := 0
+	if id > 0 {
+		start = int(index.uint32At(index.stringOffsets + int(id-1)*4))
+	}
+	end := int(index.uint32At(index.stringOffsets + int(id)*4))
+	if start > end || index.stringData+end > len(index.data) {
+		return nil
+	}
+
+	return index.data[index.stringData+start : index.stringData+end]
+}
+
+// uint32At returns the number at the given offset, or 0 if it is out of
+// bounds.
+func (index *MappingIndex) uint32At(offset int) uint32 {
+	if offset < 0 || offset+4 > len(index.data) {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(index.data[offset:])
+}
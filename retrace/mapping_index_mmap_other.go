@@ -0,0 +1,19 @@
+//go:build !unix
+
+package retrace
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile Reads the given file into memory, since it can't be mapped on
+// this platform, and returns its data and a function to release it.
+func mapFile(file *os.File) ([]byte, func() error, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package retrace
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile Maps the given file into memory read-only, and returns its data
+// and a function to unmap it.
+func mapFile(file *os.File) ([]byte, func() error, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := int(info.Size())
+	if size == 0 {
+		return nil, func() error { return nil }, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,122 @@
+package retrace
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMappingIndex(t *testing.T) {
+	remapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(mappingData+`com.example.Extra -> g:
+# {"id":"sourceFile","fileName":"Extra.kt"}
+`)).Pump(remapper))
+
+	buffer := bytes.NewBufferString("")
+	assert.NoError(t, WriteMappingIndex(remapper, buffer))
+
+	// The index is the same every time.
+	buffer2 := bytes.NewBufferString("")
+	assert.NoError(t, WriteMappingIndex(remapper, buffer2))
+	assert.Equal(t, buffer.Bytes(), buffer2.Bytes())
+
+	assert.True(t, IsMappingIndex(buffer.Bytes()))
+	index, err := ReadMappingIndex(buffer)
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(remapper.ClassMap), index.ClassCount())
+	assert.Equal(t, []string{"compiler: R8", "compiler_version: 1.4.94", "min_api: 21"}, index.HeaderComments())
+	assert.Equal(t, []string{`{"id":"sourceFile","fileName":"Extra.kt"}`}, index.ClassComments("com.example.Extra"))
+	assert.Nil(t, index.ClassComments("com.example.Missing"))
+
+	assert.Equal(t, "android.arch.core.executor.ArchTaskExecutor", index.GetOriginalClassName("c"))
+	assert.Equal(t, "zz", index.GetOriginalClassName("zz"))
+
+	frames := []FrameInfo{
+		{ClassName: "c", MethodName: "getInstance", LineNumber: 4},
+		{ClassName: "c", FieldName: "qb"},
+		{ClassName: "d", FieldName: "oe", Type: "java.util.concurrent.ExecutorService"},
+		{ClassName: "a", MethodName: "execute", LineNumber: 2},
+		{ClassName: "f", MethodName: "remove", LineNumber: 13},
+		{ClassName: "f", MethodName: "putIfAbsent", Arguments: "java.lang.Object,java.lang.Object"},
+		{ClassName: "e", MethodName: "b"},
+		{ClassName: "e", MethodName: "missing", LineNumber: 3},
+		{ClassName: "zz", MethodName: "a", LineNumber: 1},
+	}
+	for _, frame := range frames {
+		assert.Equal(t, remapper.Transform(&frame), index.Transform(&frame))
+	}
+}
+
+func TestMappingIndexInvalid(t *testing.T) {
+	_, err := NewMappingIndex([]byte("com.example.Foo -> a:\n"))
+	assert.Error(t, err)
+
+	_, err = NewMappingIndex([]byte("GRMI\x02\x00\x00\x00"))
+	assert.EqualError(t, err, "unsupported mapping index version 2, expected 1")
+
+	buffer := bytes.NewBufferString("")
+	remapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(mappingData)).Pump(remapper))
+	assert.NoError(t, WriteMappingIndex(remapper, buffer))
+
+	_, err = NewMappingIndex(buffer.Bytes()[:100])
+	assert.EqualError(t, err, "truncated mapping index")
+}
+
+func TestOpenMappingIndex(t *testing.T) {
+	remapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(mappingData)).Pump(remapper))
+
+	indexFilePath := filepath.Join(t.TempDir(), "mapping.idx")
+	indexFile, err := os.Create(indexFilePath)
+	assert.NoError(t, err)
+	assert.NoError(t, WriteMappingIndex(remapper, indexFile))
+	assert.NoError(t, indexFile.Close())
+
+	index, err := OpenMappingIndex(indexFilePath)
+	assert.NoError(t, err)
+
+	frame := FrameInfo{ClassName: "f", MethodName: "remove", LineNumber: 13}
+	frames := index.Transform(&frame)
+	assert.Equal(t, remapper.Transform(&frame), frames)
+
+	// The frames remain valid after the index is closed, but the index
+	// doesn't find any mappings anymore.
+	assert.NoError(t, index.Close())
+	assert.Equal(t, "android.arch.core.internal.SafeIterableMap", frames[0].ClassName)
+	assert.Equal(t, "c", index.GetOriginalClassName("c"))
+
+	_, err = OpenMappingIndex(filepath.Join(t.TempDir(), "missing.idx"))
+	assert.Error(t, err)
+}
+
+func TestMappingIndexSynthesized(t *testing.T) {
+	remapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(syntheticMapping)).Pump(remapper))
+
+	buffer := bytes.NewBufferString("")
+	assert.NoError(t, WriteMappingIndex(remapper, buffer))
+	index, err := ReadMappingIndex(buffer)
+	assert.NoError(t, err)
+
+	assert.True(t, index.IsSynthesized("com.example.Foo", "d"))
+	assert.True(t, index.IsSynthesized("com.example.Foo$$ExternalSyntheticLambda0", ""))
+	assert.False(t, index.IsSynthesized("com.example.Foo", "c"))
+	assert.False(t, index.IsSynthesized("com.example.Bar", "a"))
+
+	// Retracing with the index hides and folds the same frames as retracing
+	// with the mapping.
+	for _, mode := range []SyntheticFrameMode{SyntheticFramesHide, SyntheticFramesFold} {
+		retrace := NewRetraceWithRemapper(index)
+		retrace.SyntheticFrames = mode
+
+		result := bytes.NewBufferString("")
+		retrace.Retrace(strings.NewReader(syntheticTrace), result)
+		assert.Equal(t, retraceSynthetic(t, mode), result.String())
+	}
+}
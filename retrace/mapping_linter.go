@@ -0,0 +1,230 @@
+package retrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type LintSeverity string
+
+const (
+	// LintError The mapping is broken, and retracing with it goes wrong.
+	LintError LintSeverity = "error"
+	// LintWarning The mapping is suspicious, and retracing with it may be
+	// ambiguous.
+	LintWarning LintSeverity = "warning"
+)
+
+// LintFinding A problem at a line of a mapping file.
+type LintFinding struct {
+	LineNumber int          `json:"lineNumber"`
+	Severity   LintSeverity `json:"severity"`
+	Message    string       `json:"message"`
+}
+
+func (finding LintFinding) String() string {
+	return fmt.Sprintf("%d: %s: %s", finding.LineNumber, finding.Severity, finding.Message)
+}
+
+// lintLineRange An obfuscated line range of a method and the line at which
+// it starts.
+type lintLineRange struct {
+	firstLineNumber int
+	lastLineNumber  int
+	lineNumber      int
+}
+
+// lintRecorder This MappingProcessor remembers the last mapping that it
+// received, so the linter can check it.
+type lintRecorder struct {
+	processed  bool
+	isMethod   bool
+	methodInfo MethodInfo
+	newName    string
+}
+
+func (recorder *lintRecorder) ProcessClassMapping(className string, newClassName string) bool {
+	recorder.processed = true
+	return true
+}
+
+func (recorder *lintRecorder) ProcessFieldMapping(
+	className string,
+	fieldType string,
+	fieldName string,
+	newClassName string,
+	newFieldName string) {
+
+	recorder.processed = true
+	recorder.isMethod = false
+	recorder.newName = newFieldName
+}
+
+func (recorder *lintRecorder) ProcessMethodMapping(
+	className string,
+	firstLineNumber int,
+	lastLineNumber int,
+	methodType string,
+	methodName string,
+	arguments string,
+	newClassName string,
+	newFirstLineNumber int,
+	newLastLineNumber int,
+	newMethodName string) {
+
+	recorder.processed = true
+	recorder.isMethod = true
+	recorder.methodInfo = MethodInfo{
+		newFirstLineNumber,
+		newLastLineNumber,
+		className,
+		firstLineNumber,
+		lastLineNumber,
+		methodType,
+		methodName,
+		arguments,
+	}
+	recorder.newName = newMethodName
+}
+
+// LintMapping Checks the given mapping file for problems, like lines that
+// MappingReader can't parse, and returns them in the order of their lines.
+func LintMapping(fileReader io.Reader) ([]LintFinding, error) {
+	var findings []LintFinding
+	report := func(lineNumber int, severity LintSeverity, format string, args ...interface{}) {
+		findings = append(findings, LintFinding{lineNumber, severity, fmt.Sprintf(format, args...)})
+	}
+
+	reader := NewMappingReader(nil)
+	recorder := lintRecorder{}
+
+	var (
+		className string
+		// Obfuscated class name -> line number of its class mapping.
+		classLineNumbers = make(map[string]int)
+		// Obfuscated method name -> line ranges of the current class.
+		methodLineRanges map[string][]lintLineRange
+		// The line range of the current inline group.
+		lastMethodName  string
+		lastMethodRange lintLineRange
+		inMethodGroup   bool
+	)
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(fileReader)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		// Is it a comment line ?
+		if strings.HasPrefix(line, "#") {
+			// R8 writes its metadata as JSON.
+			comment := strings.TrimSpace(line[1:])
+			if strings.HasPrefix(comment, "{") && !json.Valid([]byte(comment)) {
+				report(lineNumber, LintError, "metadata is not valid JSON: %s", comment)
+			}
+			continue
+		}
+
+		recorder = lintRecorder{}
+
+		// Is it a class mapping or a class member mapping
+		if strings.HasSuffix(line, ":") {
+			className = reader.ProcessClassMapping(line, &recorder)
+			methodLineRanges = make(map[string][]lintLineRange)
+			inMethodGroup = false
+
+			if len(className) == 0 {
+				report(lineNumber, LintError, "malformed class mapping: %s", line)
+				continue
+			}
+
+			newClassName := strings.TrimSpace(line[IndexOf(line, "->", 0)+2 : len(line)-1])
+			if firstLineNumber, ok := classLineNumbers[newClassName]; ok {
+				report(lineNumber, LintError, "obfuscated class name %s is already used at line %d", newClassName, firstLineNumber)
+			} else {
+				classLineNumbers[newClassName] = lineNumber
+			}
+			continue
+		}
+
+		if methodLineRanges == nil {
+			report(lineNumber, LintError, "class member mapping before any class mapping: %s", line)
+			continue
+		}
+
+		if len(className) == 0 {
+			// The class mapping is already reported.
+			continue
+		}
+
+		if err := reader.ProcessClassMemberMapping(className, line, &recorder); err != nil {
+			report(lineNumber, LintError, "malformed class member mapping (%s): %s", err, line)
+			inMethodGroup = false
+			continue
+		}
+
+		if !recorder.processed {
+			report(lineNumber, LintError, "malformed class member mapping: %s", line)
+			inMethodGroup = false
+			continue
+		}
+
+		if !recorder.isMethod {
+			inMethodGroup = false
+			continue
+		}
+
+		methodInfo := recorder.methodInfo
+		if methodInfo.ObfuscatedFirstLineNumber > methodInfo.ObfuscatedLastLineNumber {
+			report(lineNumber, LintError, "reversed obfuscated line range %d:%d",
+				methodInfo.ObfuscatedFirstLineNumber, methodInfo.ObfuscatedLastLineNumber)
+		}
+		if methodInfo.OriginalFirstLineNumber > methodInfo.OriginalLastLineNumber {
+			report(lineNumber, LintError, "reversed original line range %d:%d",
+				methodInfo.OriginalFirstLineNumber, methodInfo.OriginalLastLineNumber)
+		}
+
+		if methodInfo.ObfuscatedLastLineNumber == 0 {
+			inMethodGroup = false
+			continue
+		}
+
+		// Methods in the same line range right after each other are
+		// inlined into each other.
+		lineRange := lintLineRange{methodInfo.ObfuscatedFirstLineNumber, methodInfo.ObfuscatedLastLineNumber, lineNumber}
+		if inMethodGroup &&
+			recorder.newName == lastMethodName &&
+			lineRange.firstLineNumber == lastMethodRange.firstLineNumber &&
+			lineRange.lastLineNumber == lastMethodRange.lastLineNumber {
+			continue
+		}
+
+		for _, otherRange := range methodLineRanges[recorder.newName] {
+			if lineRange.firstLineNumber <= otherRange.lastLineNumber &&
+				otherRange.firstLineNumber <= lineRange.lastLineNumber {
+				report(lineNumber, LintWarning, "obfuscated line range %d:%d of method %s overlaps line range %d:%d at line %d",
+					lineRange.firstLineNumber, lineRange.lastLineNumber, recorder.newName,
+					otherRange.firstLineNumber, otherRange.lastLineNumber, otherRange.lineNumber)
+				break
+			}
+		}
+
+		methodLineRanges[recorder.newName] = append(methodLineRanges[recorder.newName], lineRange)
+		lastMethodName = recorder.newName
+		lastMethodRange = lineRange
+		inMethodGroup = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return findings, err
+	}
+
+	return findings, nil
+}
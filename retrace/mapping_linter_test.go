@@ -0,0 +1,41 @@
+package retrace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintMapping(t *testing.T) {
+	findings, err := LintMapping(strings.NewReader(mappingData))
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+
+	findings, err = LintMapping(strings.NewReader(`# compiler: R8
+    void orphan() -> a
+com.example.Foo -> a:
+# {"id":"sourceFile","fileName":"Foo.kt"
+    int count -> a
+    1:3:void bar():10:12 -> b
+    4:4:void com.example.Baz.helper():30:30 -> b
+    4:4:void bar():13 -> b
+    3:5:void baz():20:22 -> b
+    7:6:void qux():1:2 -> c
+    1:x:void broken() -> d
+    garbage
+com.example.Bar -> a:
+no arrow:
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []LintFinding{
+		{2, LintError, "class member mapping before any class mapping: void orphan() -> a"},
+		{4, LintError, `metadata is not valid JSON: {"id":"sourceFile","fileName":"Foo.kt"`},
+		{9, LintWarning, "obfuscated line range 3:5 of method b overlaps line range 1:3 at line 6"},
+		{10, LintError, "reversed obfuscated line range 7:6"},
+		{11, LintError, `malformed class member mapping (strconv.Atoi: parsing "x": invalid syntax): 1:x:void broken() -> d`},
+		{12, LintError, "malformed class member mapping (spaceIndex < 0 or arrowIndex < 0): garbage"},
+		{13, LintError, "obfuscated class name a is already used at line 3"},
+		{14, LintError, "malformed class mapping: no arrow:"},
+	}, findings)
+}
@@ -0,0 +1,163 @@
+package retrace
+
+import (
+	"io"
+)
+
+// MappingConflict An obfuscated class name that two mappings claim for
+// different original classes, or an original class that two mappings map to
+// different obfuscated class names.
+type MappingConflict struct {
+	// The class of the mapping that takes precedence.
+	ObfuscatedClassName string
+	ClassName           string
+	MappingName         string
+	// The class of the mapping that is ignored.
+	IgnoredObfuscatedClassName string
+	IgnoredClassName           string
+	IgnoredMappingName         string
+	// Merged Whether the class of the later mapping is merged nevertheless,
+	// because the conflict is an original class under different obfuscated
+	// class names, which don't collide.
+	Merged bool
+}
+
+// MappingMerger This MappingProcessor merges several mappings, like the
+// mappings of dynamic feature modules or of pre-obfuscated SDKs, into a
+// single FrameRemapper.
+//
+// The mappings take precedence in the order in which they are merged: if a
+// later mapping claims an obfuscated class name that an earlier mapping
+// already claimed, its class mapping and class member mappings are ignored,
+// and the conflict is reported if the original class names differ. If a later
+// mapping maps an original class that an earlier mapping already mapped to a
+// different obfuscated class name, its mappings are merged, since the
+// obfuscated class names don't collide, and the conflict is reported for
+// information. The class members of both mappings then belong to the same
+// original class, so the ones with the same obfuscated names are ambiguous.
+//
+// Usage:
+//
+//	merger := NewMappingMerger(remapper)
+//	err := merger.Merge("app", appMappingReader)
+//	err = merger.Merge("sdk", sdkMappingReader)
+//	conflicts := merger.Conflicts
+type MappingMerger struct {
+	Remapper  *FrameRemapper
+	Conflicts []MappingConflict
+	// Workers The number of goroutines that parse each mapping, like
+	// MappingReader.Workers.
+	Workers int
+
+	// Obfuscated class name -> name of the mapping that claimed it.
+	classOwners map[string]string
+	// Original class name -> obfuscated class name that claimed it.
+	originalClassNames map[string]string
+	// The mapping that is being merged.
+	mappingName string
+}
+
+func NewMappingMerger(remapper *FrameRemapper) *MappingMerger {
+	merger := MappingMerger{
+		Remapper:           remapper,
+		classOwners:        make(map[string]string),
+		originalClassNames: make(map[string]string),
+	}
+
+	return &merger
+}
+
+// Merge Reads the given mapping into the remapper.
+//
+// Parameters:
+//
+//	mappingName the name of the mapping, like its file name, to report
+//	            conflicts with.
+//	fileReader  the mapping.
+func (merger *MappingMerger) Merge(mappingName string, fileReader io.Reader) error {
+	merger.mappingName = mappingName
+
+	// Comments before the first class mapping belong to the header of this
+	// mapping rather than to the last class of the previous mapping.
+	merger.Remapper.commentClassName = ""
+
+	return NewParallelMappingReader(fileReader, merger.Workers).Pump(merger)
+}
+
+func (merger *MappingMerger) ProcessClassMapping(className string, newClassName string) bool {
+	if owner, ok := merger.classOwners[newClassName]; ok && owner != merger.mappingName {
+		// The obfuscated class name is already claimed.
+		if existingClassName := merger.Remapper.ClassMap[newClassName]; existingClassName != className {
+			merger.Conflicts = append(merger.Conflicts, MappingConflict{
+				ObfuscatedClassName:        newClassName,
+				ClassName:                  existingClassName,
+				MappingName:                owner,
+				IgnoredObfuscatedClassName: newClassName,
+				IgnoredClassName:           className,
+				IgnoredMappingName:         merger.mappingName,
+			})
+		}
+		return false
+	}
+
+	if existingClassName, ok := merger.originalClassNames[className]; ok && existingClassName != newClassName {
+		// The original class is already mapped to another obfuscated class
+		// name.
+		if owner := merger.classOwners[existingClassName]; owner != merger.mappingName {
+			merger.Conflicts = append(merger.Conflicts, MappingConflict{
+				ObfuscatedClassName:        existingClassName,
+				ClassName:                  className,
+				MappingName:                owner,
+				IgnoredObfuscatedClassName: newClassName,
+				IgnoredClassName:           className,
+				IgnoredMappingName:         merger.mappingName,
+				Merged:                     true,
+			})
+		}
+	} else {
+		merger.originalClassNames[className] = newClassName
+	}
+
+	merger.classOwners[newClassName] = merger.mappingName
+	return merger.Remapper.ProcessClassMapping(className, newClassName)
+}
+
+func (merger *MappingMerger) ProcessFieldMapping(
+	className string,
+	fieldType string,
+	fieldName string,
+	newClassName string,
+	newFieldName string) {
+
+	merger.Remapper.ProcessFieldMapping(className, fieldType, fieldName, newClassName, newFieldName)
+}
+
+func (merger *MappingMerger) ProcessMethodMapping(
+	className string,
+	firstLineNumber int,
+	lastLineNumber int,
+	methodType string,
+	methodName string,
+	arguments string,
+	newClassName string,
+	newFirstLineNumber int,
+	newLastLineNumber int,
+	newMethodName string) {
+
+	merger.Remapper.ProcessMethodMapping(
+		className,
+		firstLineNumber,
+		lastLineNumber,
+		methodType,
+		methodName,
+		arguments,
+		newClassName,
+		newFirstLineNumber,
+		newLastLineNumber,
+		newMethodName,
+	)
+}
+
+func (merger *MappingMerger) ProcessComment(comment string) {
+	merger.Remapper.ProcessComment(comment)
+}
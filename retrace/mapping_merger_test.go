@@ -0,0 +1,86 @@
+package retrace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMappingMerger(t *testing.T) {
+	remapper := NewFrameRemapper()
+	merger := NewMappingMerger(remapper)
+
+	assert.NoError(t, merger.Merge("app", strings.NewReader(`# pg_map_id: 1a2b3c4
+com.example.App -> a:
+    1:1:void onCreate():10:10 -> a
+com.example.Shared -> c:
+    void run() -> a
+`)))
+	assert.NoError(t, merger.Merge("sdk", strings.NewReader(`# pg_map_id: 5d6e7f8
+com.sdk.Client -> a:
+    1:1:void connect():20:20 -> a
+com.sdk.Api -> b:
+    1:1:void call():30:30 -> a
+com.example.Shared -> c:
+    void run() -> a
+`)))
+
+	assert.Equal(t, []MappingConflict{
+		{
+			ObfuscatedClassName:        "a",
+			ClassName:                  "com.example.App",
+			MappingName:                "app",
+			IgnoredObfuscatedClassName: "a",
+			IgnoredClassName:           "com.sdk.Client",
+			IgnoredMappingName:         "sdk",
+		},
+	}, merger.Conflicts)
+
+	assert.Equal(t, "com.example.App", remapper.GetOriginalClassName("a"))
+	assert.Equal(t, "com.sdk.Api", remapper.GetOriginalClassName("b"))
+	assert.Equal(t, []string{"pg_map_id: 1a2b3c4", "pg_map_id: 5d6e7f8"}, remapper.HeaderComments)
+
+	frames := remapper.Transform(&FrameInfo{ClassName: "a", MethodName: "a", LineNumber: 1})
+	assert.Equal(t, 1, len(frames))
+	assert.Equal(t, "onCreate", frames[0].MethodName)
+}
+
+func TestMappingMergerOriginalClassConflict(t *testing.T) {
+	remapper := NewFrameRemapper()
+	merger := NewMappingMerger(remapper)
+
+	assert.NoError(t, merger.Merge("app", strings.NewReader(`com.example.Shared -> c:
+    1:1:void run():10:10 -> a
+`)))
+	assert.NoError(t, merger.Merge("sdk", strings.NewReader(`com.example.Shared -> d:
+    1:1:void stop():20:20 -> b
+    int count -> c
+`)))
+
+	assert.Equal(t, []MappingConflict{
+		{
+			ObfuscatedClassName:        "c",
+			ClassName:                  "com.example.Shared",
+			MappingName:                "app",
+			IgnoredObfuscatedClassName: "d",
+			IgnoredClassName:           "com.example.Shared",
+			IgnoredMappingName:         "sdk",
+			Merged:                     true,
+		},
+	}, merger.Conflicts)
+
+	// Both obfuscated class names are retraced, with the class members of
+	// both mappings.
+	frames := remapper.Transform(&FrameInfo{ClassName: "c", MethodName: "a", LineNumber: 1})
+	assert.Equal(t, 1, len(frames))
+	assert.Equal(t, "com.example.Shared", frames[0].ClassName)
+	assert.Equal(t, "run", frames[0].MethodName)
+
+	frames = remapper.Transform(&FrameInfo{ClassName: "d", MethodName: "b", LineNumber: 1})
+	assert.Equal(t, 1, len(frames))
+	assert.Equal(t, "com.example.Shared", frames[0].ClassName)
+	assert.Equal(t, "stop", frames[0].MethodName)
+	assert.Equal(t, "com.example.Shared", remapper.GetOriginalClassName("d"))
+	assert.Len(t, remapper.fieldInfos("com.example.Shared", "c"), 1)
+}
@@ -1,10 +1,7 @@
 package retrace
 
 import (
-	"strings"
-
-	"github.com/emirpasic/gods/sets/hashset"
-	"github.com/emirpasic/gods/sets/linkedhashset"
+	"sort"
 )
 
 // Reference: https://github.com/Guardsquare/proguard/blob/0344c58b3d43799ce203737eea3fd1b58ca701ad/retrace/src/proguard/retrace/FrameRemapper.java
@@ -69,6 +66,22 @@ type MappingProcessor interface {
 		newMethodName string)
 }
 
+// MappingCommentProcessor This interface can optionally be implemented by a
+// MappingProcessor that also wants to receive the comment lines of a mapping
+// file, such as the "# compiler: R8" header or R8's JSON metadata like
+// "# {"id":"sourceFile","fileName":"Foo.kt"}".
+//
+// Comments are reported in file order, so they belong to the header if no
+// class mapping has been processed yet, and otherwise to the class or class
+// member mapping that was processed last.
+type MappingCommentProcessor interface {
+	// ProcessComment Processes the given comment.
+	//
+	// Parameters:
+	//    comment the comment, without its leading '#'.
+	ProcessComment(comment string)
+}
+
 type FieldInfo struct {
 	OriginalClassName string
 	OriginalType      string
@@ -102,8 +115,45 @@ func (info *MethodInfo) Matches(obfuscatedLineNumber int, originalType string, o
 
 }
 
-type ObfuscatedNameFieldInfoSetMap map[string]*hashset.Set
-type ObfuscatedNameMethodInfoSetMap map[string]*linkedhashset.Set
+// OriginalLineNumber returns the original line number for the given obfuscated
+// line number, which may be 0 if it is not known.
+func (info *MethodInfo) OriginalLineNumber(obfuscatedLineNumber int) int {
+	lineNumber := obfuscatedLineNumber
+	if info.OriginalFirstLineNumber != info.ObfuscatedFirstLineNumber {
+		if info.OriginalLastLineNumber != 0 &&
+			info.OriginalLastLineNumber != info.OriginalFirstLineNumber &&
+			info.ObfuscatedFirstLineNumber != 0 &&
+			lineNumber != 0 {
+			lineNumber = info.OriginalFirstLineNumber - info.ObfuscatedFirstLineNumber + lineNumber
+		} else {
+			lineNumber = info.OriginalFirstLineNumber
+		}
+	}
+
+	return lineNumber
+}
+
+// ObfuscatedLineNumbers returns the range of obfuscated line numbers for the
+// given original line number, which is the reverse of OriginalLineNumber.
+func (info *MethodInfo) ObfuscatedLineNumbers(originalLineNumber int) (int, int) {
+	if info.OriginalFirstLineNumber == info.ObfuscatedFirstLineNumber {
+		return originalLineNumber, originalLineNumber
+	}
+
+	if info.OriginalLastLineNumber != 0 &&
+		info.OriginalLastLineNumber != info.OriginalFirstLineNumber &&
+		info.ObfuscatedFirstLineNumber != 0 &&
+		originalLineNumber != 0 {
+		lineNumber := info.ObfuscatedFirstLineNumber - info.OriginalFirstLineNumber + originalLineNumber
+		return lineNumber, lineNumber
+	}
+
+	return info.ObfuscatedFirstLineNumber, info.ObfuscatedLastLineNumber
+}
+
+type ObfuscatedNameFieldInfoSetMap map[string]*FieldInfoSet
+type ObfuscatedNameMethodInfoSetMap map[string]*MethodInfoSet
+type ObfuscatedNameCommentsMap map[string][]string
 
 type FrameRemapper struct {
 	// ClassMap Obfuscated class name -> original class name.
@@ -111,13 +161,34 @@ type FrameRemapper struct {
 	// ClassFieldMap Original class name -> obfuscated member name -> member info set.
 	ClassFieldMap  map[string]ObfuscatedNameFieldInfoSetMap
 	ClassMethodMap map[string]ObfuscatedNameMethodInfoSetMap
+
+	// HeaderComments The comments before the first class mapping.
+	HeaderComments []string
+	// ClassComments Original class name -> comments following the class mapping.
+	ClassComments map[string][]string
+	// ClassFieldComments Original class name -> obfuscated member name -> comments
+	// following the member mappings.
+	ClassFieldComments  map[string]ObfuscatedNameCommentsMap
+	ClassMethodComments map[string]ObfuscatedNameCommentsMap
+
+	// The canonical copies of the strings of the mappings.
+	strings map[string]string
+
+	// The class and member that the next comment belongs to.
+	commentClassName  string
+	commentMemberName string
+	commentIsMethod   bool
 }
 
 func NewFrameRemapper() *FrameRemapper {
 	remapper := FrameRemapper{
-		ClassMap:       make(map[string]string),
-		ClassFieldMap:  make(map[string]ObfuscatedNameFieldInfoSetMap),
-		ClassMethodMap: make(map[string]ObfuscatedNameMethodInfoSetMap),
+		ClassMap:            make(map[string]string),
+		ClassFieldMap:       make(map[string]ObfuscatedNameFieldInfoSetMap),
+		ClassMethodMap:      make(map[string]ObfuscatedNameMethodInfoSetMap),
+		ClassComments:       make(map[string][]string),
+		ClassFieldComments:  make(map[string]ObfuscatedNameCommentsMap),
+		ClassMethodComments: make(map[string]ObfuscatedNameCommentsMap),
+		strings:             make(map[string]string),
 	}
 
 	return &remapper
@@ -125,10 +196,39 @@ func NewFrameRemapper() *FrameRemapper {
 
 func (remapper *FrameRemapper) ProcessClassMapping(className string, newClassName string) bool {
 	// Obfuscated class name -> original class name
-	remapper.ClassMap[newClassName] = className
+	className = remapper.intern(className)
+	remapper.ClassMap[remapper.intern(newClassName)] = className
+
+	remapper.commentClassName = className
+	remapper.commentMemberName = ""
 	return true
 }
 
+func (remapper *FrameRemapper) ProcessComment(comment string) {
+	if len(remapper.commentClassName) == 0 {
+		remapper.HeaderComments = append(remapper.HeaderComments, comment)
+		return
+	}
+
+	if len(remapper.commentMemberName) == 0 {
+		remapper.ClassComments[remapper.commentClassName] = append(remapper.ClassComments[remapper.commentClassName], comment)
+		return
+	}
+
+	classComments := remapper.ClassFieldComments
+	if remapper.commentIsMethod {
+		classComments = remapper.ClassMethodComments
+	}
+
+	// Original class name -> obfuscated member name -> comments
+	commentsMap, ok := classComments[remapper.commentClassName]
+	if !ok {
+		commentsMap = make(ObfuscatedNameCommentsMap)
+		classComments[remapper.commentClassName] = commentsMap
+	}
+	commentsMap[remapper.commentMemberName] = append(commentsMap[remapper.commentMemberName], comment)
+}
+
 func (remapper *FrameRemapper) ProcessFieldMapping(
 	className string,
 	fieldType string,
@@ -142,23 +242,26 @@ func (remapper *FrameRemapper) ProcessFieldMapping(
 	fieldMap, ok = remapper.ClassFieldMap[newClassName]
 	if !ok {
 		fieldMap = make(ObfuscatedNameFieldInfoSetMap)
-		remapper.ClassFieldMap[newClassName] = fieldMap
+		remapper.ClassFieldMap[remapper.intern(newClassName)] = fieldMap
 	}
 
 	// Obfuscated field name -> fields
-	var fieldInfoSet *hashset.Set
+	var fieldInfoSet *FieldInfoSet
 	fieldInfoSet, ok = fieldMap[newFieldName]
 	if !ok {
-		fieldInfoSet = hashset.New()
-		fieldMap[newFieldName] = fieldInfoSet
+		fieldInfoSet = &FieldInfoSet{}
+		fieldMap[remapper.intern(newFieldName)] = fieldInfoSet
 	}
 
 	// Add the field information
 	fieldInfoSet.Add(FieldInfo{
-		OriginalClassName: className,
-		OriginalType:      fieldType,
-		OriginalName:      fieldName,
+		OriginalClassName: remapper.intern(className),
+		OriginalType:      remapper.intern(fieldType),
+		OriginalName:      remapper.intern(fieldName),
 	})
+
+	remapper.commentMemberName = newFieldName
+	remapper.commentIsMethod = false
 }
 
 func (remapper *FrameRemapper) ProcessMethodMapping(
@@ -179,188 +282,132 @@ func (remapper *FrameRemapper) ProcessMethodMapping(
 	methodMap, ok = remapper.ClassMethodMap[newClassName]
 	if !ok {
 		methodMap = make(ObfuscatedNameMethodInfoSetMap)
-		remapper.ClassMethodMap[newClassName] = methodMap
+		remapper.ClassMethodMap[remapper.intern(newClassName)] = methodMap
 	}
 
 	// Obfuscated method name -> methods
-	var methodInfoSet *linkedhashset.Set
+	var methodInfoSet *MethodInfoSet
 	methodInfoSet, ok = methodMap[newMethodName]
 	if !ok {
-		methodInfoSet = linkedhashset.New()
-		methodMap[newMethodName] = methodInfoSet
+		methodInfoSet = &MethodInfoSet{}
+		methodMap[remapper.intern(newMethodName)] = methodInfoSet
 	}
 
 	// Add the method information
 	methodInfoSet.Add(MethodInfo{
 		newFirstLineNumber,
 		newLastLineNumber,
-		className,
+		remapper.intern(className),
 		firstLineNumber,
 		lastLineNumber,
-		methodType,
-		methodName,
-		arguments,
+		remapper.intern(methodType),
+		remapper.intern(methodName),
+		remapper.intern(arguments),
 	})
-}
-
-func (remapper *FrameRemapper) Transform(obfuscatedFrame *FrameInfo) []FrameInfo {
-	// First remap the class name.
-	originalClassName := remapper.GetOriginalClassName(obfuscatedFrame.ClassName)
-
-	// Create any transformed frames with remapped field names.
-	var originalFrames []FrameInfo
-	originalFrames = remapper.transformFieldInfo(*obfuscatedFrame, originalClassName, originalFrames)
-
-	// Create any transformed frames with remapped method names.
-	originalFrames = remapper.transformMethodInfo(*obfuscatedFrame, originalClassName, originalFrames)
-
-	if len(originalFrames) == 0 {
-		// No remapping was possible, so just use the original frame.
-		var sourceFile string = obfuscatedFrame.SourceFile
-		if len(sourceFile) == 0 && sourceFile != "Unknown Source" && sourceFile != "Native Method" {
-			sourceFile = remapper.getSourceFileName(originalClassName)
-		}
-
-		originalFrames = append(originalFrames, FrameInfo{
-			originalClassName,
-			sourceFile,
-			obfuscatedFrame.LineNumber,
-			obfuscatedFrame.Type,
-			obfuscatedFrame.FieldName,
-			obfuscatedFrame.MethodName,
-			obfuscatedFrame.Arguments,
-		})
-	}
 
-	return originalFrames
+	remapper.commentMemberName = newMethodName
+	remapper.commentIsMethod = true
 }
 
-/**
- * transformFieldInfo
- * Transforms the obfuscated frame into one or more original frames,
- * if the frame contains information about a field that can be remapped.
- * @param obfuscatedFrame     the obfuscated frame.
- * @param originalFieldFrames the list in which remapped frames can be
- *                            collected.
- */
-func (remapper *FrameRemapper) transformFieldInfo(obfuscatedFrame FrameInfo, originalClassName string, originalFieldFrames []FrameInfo) []FrameInfo {
-	// Class name -> obfuscated field names
-	fieldMap, ok := remapper.ClassFieldMap[originalClassName]
-	if !ok {
-		return originalFieldFrames
+// Pump Replays all mappings of this remapper to the given mapping processor,
+// as if they were read from a mapping file. Classes are replayed in the order
+// of their original names and their members in the order of their obfuscated
+// names, so the result doesn't depend on map iteration order.
+func (remapper *FrameRemapper) Pump(processor MappingProcessor) {
+	commentProcessor, _ := processor.(MappingCommentProcessor)
+	pumpComments(commentProcessor, remapper.HeaderComments)
+
+	newClassNames := make([]string, 0, len(remapper.ClassMap))
+	for newClassName := range remapper.ClassMap {
+		newClassNames = append(newClassNames, newClassName)
 	}
+	sort.Slice(newClassNames, func(i, j int) bool {
+		return remapper.ClassMap[newClassNames[i]] < remapper.ClassMap[newClassNames[j]]
+	})
 
-	// Obfuscated field names -> fields
-	obfuscatedFieldName := obfuscatedFrame.FieldName
-	fieldSet, ok := fieldMap[obfuscatedFieldName]
-	if !ok {
-		return originalFieldFrames
-	}
-
-	originalType := remapper.getOriginalType(obfuscatedFrame.Type)
-
-	// Find all matching fields
-	for _, item := range fieldSet.Values() {
-		fieldInfo := item.(FieldInfo)
-		if !fieldInfo.Matches(originalType) {
+	for _, newClassName := range newClassNames {
+		className := remapper.ClassMap[newClassName]
+		if !processor.ProcessClassMapping(className, newClassName) {
 			continue
 		}
-
-		originalFieldFrames = append(originalFieldFrames, FrameInfo{
-			fieldInfo.OriginalClassName,
-			remapper.getSourceFileName(fieldInfo.OriginalClassName),
-			obfuscatedFrame.LineNumber,
-			fieldInfo.OriginalType,
-			fieldInfo.OriginalName,
-			obfuscatedFrame.MethodName,
-			obfuscatedFrame.Arguments,
-		})
-	}
-
-	return originalFieldFrames
-}
-
-/**
- * transformMethodInfo
- * Transforms the obfuscated frame into one or more original frames,
- * if the frame contains information about a method that can be remapped.
- * @param obfuscatedFrame      the obfuscated frame.
- * @param originalMethodFrames the list in which remapped frames can be
- *                             collected.
- */
-func (remapper *FrameRemapper) transformMethodInfo(obfuscatedFrame FrameInfo, originalClassName string, originalMethodFrames []FrameInfo) []FrameInfo {
-	// Class name -> obfuscated method names
-	methodMap, ok := remapper.ClassMethodMap[originalClassName]
-	if !ok {
-		return originalMethodFrames
-	}
-
-	// Obfuscated method names -> methods
-	obfuscatedMethodName := obfuscatedFrame.MethodName
-	methodSet, ok := methodMap[obfuscatedMethodName]
-	if !ok {
-		return originalMethodFrames
-	}
-
-	obfuscatedLineNumber := obfuscatedFrame.LineNumber
-	originalType := remapper.getOriginalType(obfuscatedFrame.Type)
-	originalArguments := remapper.getOriginalArguments(obfuscatedFrame.Arguments)
-
-	// Find all matching methods
-	for _, item := range methodSet.Values() {
-		methodInfo := item.(MethodInfo)
-		if !methodInfo.Matches(obfuscatedLineNumber, originalType, originalArguments) {
-			continue
+		pumpComments(commentProcessor, remapper.ClassComments[className])
+
+		fieldMap := remapper.ClassFieldMap[className]
+		for _, newFieldName := range sortedKeys(fieldMap) {
+			for _, fieldInfo := range fieldMap[newFieldName].Values() {
+				processor.ProcessFieldMapping(
+					fieldInfo.OriginalClassName,
+					fieldInfo.OriginalType,
+					fieldInfo.OriginalName,
+					className,
+					newFieldName,
+				)
+			}
+			pumpComments(commentProcessor, remapper.ClassFieldComments[className][newFieldName])
 		}
 
-		lineNumber := obfuscatedFrame.LineNumber
-		if methodInfo.OriginalFirstLineNumber != methodInfo.ObfuscatedFirstLineNumber {
-			if methodInfo.OriginalLastLineNumber != 0 &&
-				methodInfo.OriginalLastLineNumber != methodInfo.OriginalFirstLineNumber &&
-				methodInfo.ObfuscatedFirstLineNumber != 0 &&
-				lineNumber != 0 {
-				lineNumber = methodInfo.OriginalFirstLineNumber - methodInfo.ObfuscatedFirstLineNumber + lineNumber
-			} else {
-				lineNumber = methodInfo.OriginalFirstLineNumber
+		methodMap := remapper.ClassMethodMap[className]
+		for _, newMethodName := range sortedKeys(methodMap) {
+			// The method set keeps the order of the mapping file, which
+			// also keeps the inlined methods together.
+			for _, methodInfo := range methodMap[newMethodName].Values() {
+				processor.ProcessMethodMapping(
+					methodInfo.OriginalClassName,
+					methodInfo.OriginalFirstLineNumber,
+					methodInfo.OriginalLastLineNumber,
+					methodInfo.OriginalType,
+					methodInfo.OriginalName,
+					methodInfo.OriginalArguments,
+					className,
+					methodInfo.ObfuscatedFirstLineNumber,
+					methodInfo.ObfuscatedLastLineNumber,
+					newMethodName,
+				)
 			}
+			pumpComments(commentProcessor, remapper.ClassMethodComments[className][newMethodName])
 		}
+	}
+}
 
-		originalMethodFrames = append(originalMethodFrames, FrameInfo{
-			methodInfo.OriginalClassName,
-			remapper.getSourceFileName(methodInfo.OriginalClassName),
-			lineNumber,
-			methodInfo.OriginalType,
-			obfuscatedFrame.FieldName,
-			methodInfo.OriginalName,
-			methodInfo.OriginalArguments,
-		})
+func pumpComments(commentProcessor MappingCommentProcessor, comments []string) {
+	if commentProcessor == nil {
+		return
 	}
 
-	return originalMethodFrames
+	for _, comment := range comments {
+		commentProcessor.ProcessComment(comment)
+	}
 }
 
-func (remapper *FrameRemapper) getSourceFileName(className string) string {
-	if len(className) == 0 {
-		return className
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
-	index1 := strings.LastIndex(className, ".") + 1
-	index2 := IndexOf(className, "$", index1)
+func (remapper *FrameRemapper) Transform(obfuscatedFrame *FrameInfo) []FrameInfo {
+	return transformFrame(remapper, obfuscatedFrame)
+}
 
-	if index2 > 0 {
-		return className[index1:index2]
-	} else {
-		return className[index1:] + ".java"
-	}
+// SourceFileName Returns the source file of the given original class in R8's
+// metadata, or an empty string.
+func (remapper *FrameRemapper) SourceFileName(originalClassName string) string {
+	return sourceFileFromComments(remapper.ClassComments[originalClassName])
 }
 
-func (remapper *FrameRemapper) getOriginalType(obfuscatedType string) string {
-	index := strings.Index(obfuscatedType, "[")
-	if index >= 0 {
-		return remapper.GetOriginalClassName(obfuscatedType[0:index]) + obfuscatedType[index:]
-	} else {
-		return remapper.GetOriginalClassName(obfuscatedType)
+// IsSynthesized Returns whether R8 marked the given original class, or the
+// class member with the given obfuscated name in it, as synthesized.
+func (remapper *FrameRemapper) IsSynthesized(originalClassName string, obfuscatedMemberName string) bool {
+	if containsSynthesizedComment(remapper.ClassComments[originalClassName]) {
+		return true
 	}
+
+	return len(obfuscatedMemberName) > 0 &&
+		(containsSynthesizedComment(remapper.ClassMethodComments[originalClassName][obfuscatedMemberName]) ||
+			containsSynthesizedComment(remapper.ClassFieldComments[originalClassName][obfuscatedMemberName]))
 }
 
 func (remapper *FrameRemapper) GetOriginalClassName(obfuscatedClassName string) string {
@@ -372,17 +419,38 @@ func (remapper *FrameRemapper) GetOriginalClassName(obfuscatedClassName string)
 	}
 }
 
-func (remapper *FrameRemapper) getOriginalArguments(obfuscatedArguments string) string {
-	tokens := strings.Split(obfuscatedArguments, ",")
-	if len(tokens) < 1 {
-		return ""
+func (remapper *FrameRemapper) hasClass(obfuscatedClassName string) bool {
+	_, ok := remapper.ClassMap[obfuscatedClassName]
+	return ok
+}
+
+func (remapper *FrameRemapper) fieldInfos(originalClassName string, obfuscatedFieldName string) []FieldInfo {
+	// Class name -> obfuscated field names -> fields
+	if fieldSet, ok := remapper.ClassFieldMap[originalClassName][obfuscatedFieldName]; ok {
+		return fieldSet.Values()
 	}
 
-	originalArguments := make([]string, len(tokens))
+	return nil
+}
+
+func (remapper *FrameRemapper) methodInfos(originalClassName string, obfuscatedMethodName string, obfuscatedLineNumber int) []MethodInfo {
+	// Class name -> obfuscated method names -> methods
+	if methodSet, ok := remapper.ClassMethodMap[originalClassName][obfuscatedMethodName]; ok {
+		return methodSet.ValuesAt(obfuscatedLineNumber)
+	}
+
+	return nil
+}
 
-	for index, token := range tokens {
-		originalArguments[index] = remapper.getOriginalType(strings.TrimSpace(token))
+// intern returns the canonical copy of the given string, so repeated class
+// names, types and arguments are only stored once, and don't keep the lines
+// of the mapping file from which they were parsed in memory.
+func (remapper *FrameRemapper) intern(str string) string {
+	if interned, ok := remapper.strings[str]; ok {
+		return interned
 	}
 
-	return strings.Join(originalArguments, ",")
+	interned := string([]byte(str))
+	remapper.strings[interned] = interned
+	return interned
 }
@@ -83,6 +83,6 @@ func TestMappingProcessor(t *testing.T) {
 	frameRemapper := NewFrameRemapper()
 	mappingReader.Pump(frameRemapper)
 
-	assert.Equal(t, frameRemapper.getSourceFileName("android.arch.core.executor.ArchTaskExecutor"), "ArchTaskExecutor.java")
+	assert.Equal(t, getSourceFileName("android.arch.core.executor.ArchTaskExecutor"), "ArchTaskExecutor.java")
 	assert.Equal(t, frameRemapper.GetOriginalClassName("c"), "android.arch.core.executor.ArchTaskExecutor")
 }
@@ -10,6 +10,9 @@ import (
 
 type MappingReader struct {
 	fileReader io.Reader
+	// Workers The number of goroutines that parse the mapping, or 0 or 1
+	// to parse it sequentially.
+	Workers int
 }
 
 func IndexOf(s string, subStr string, position int) int {
@@ -30,7 +33,14 @@ func NewMappingReader(fileReader io.Reader) *MappingReader {
 }
 
 func (r *MappingReader) Pump(processor MappingProcessor) error {
+	if r.Workers > 1 {
+		return r.pumpParallel(processor)
+	}
+
 	var className string = ""
+	var seenClassMapping = false
+
+	commentProcessor, _ := processor.(MappingCommentProcessor)
 
 	scanner := bufio.NewScanner(r.fileReader)
 	for scanner.Scan() {
@@ -41,12 +51,18 @@ func (r *MappingReader) Pump(processor MappingProcessor) error {
 
 		// Is it a comment line ?
 		if strings.HasPrefix(line, "#") {
+			// Pass it on if it belongs to the header or to a class the
+			// processor is interested in.
+			if commentProcessor != nil && (!seenClassMapping || len(className) > 0) {
+				commentProcessor.ProcessComment(strings.TrimSpace(line[1:]))
+			}
 			continue
 		}
 
 		// Is it a class mapping or a class member mapping
 		if strings.HasSuffix(line, ":") {
 			// Process the class mapping and remember the class's old name
+			seenClassMapping = true
 			className = r.ProcessClassMapping(line, processor)
 		} else if len(className) > 0 {
 			// Process the class member mapping, in the context of the current old class name
@@ -186,6 +202,9 @@ func (r *MappingReader) ProcessClassMemberMapping(className string, line string,
 				newFirstLineNumber = firstLineNumber
 
 				lastLineNumber, err = strconv.Atoi(strings.TrimSpace(line[colonIndex1+1 : colonIndex2]))
+				if err != nil {
+					return err
+				}
 				newLastLineNumber = lastLineNumber
 			}
 
@@ -0,0 +1,176 @@
+package retrace
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MappingCounts The number of classes and class members in (a part of) a
+// mapping, and how many of them keep their original names.
+type MappingCounts struct {
+	ClassCount           int `json:"classCount"`
+	UnrenamedClassCount  int `json:"unrenamedClassCount"`
+	FieldCount           int `json:"fieldCount"`
+	UnrenamedFieldCount  int `json:"unrenamedFieldCount"`
+	MethodCount          int `json:"methodCount"`
+	UnrenamedMethodCount int `json:"unrenamedMethodCount"`
+}
+
+func (counts *MappingCounts) add(other *MappingCounts) {
+	counts.ClassCount += other.ClassCount
+	counts.UnrenamedClassCount += other.UnrenamedClassCount
+	counts.FieldCount += other.FieldCount
+	counts.UnrenamedFieldCount += other.UnrenamedFieldCount
+	counts.MethodCount += other.MethodCount
+	counts.UnrenamedMethodCount += other.UnrenamedMethodCount
+}
+
+// UnrenamedShare returns the share of classes and class members that keep
+// their original names, between 0 and 1.
+func (counts *MappingCounts) UnrenamedShare() float64 {
+	total := counts.ClassCount + counts.FieldCount + counts.MethodCount
+	if total == 0 {
+		return 0
+	}
+
+	return float64(counts.UnrenamedClassCount+counts.UnrenamedFieldCount+counts.UnrenamedMethodCount) / float64(total)
+}
+
+// PackageStats The counts of a single package.
+type PackageStats struct {
+	PackageName string `json:"packageName"`
+	MappingCounts
+}
+
+// MappingStats Statistics of a mapping, to audit how much of the code is
+// still readable after obfuscation.
+type MappingStats struct {
+	MappingCounts
+	Packages []PackageStats `json:"packages"`
+	// KeptPackages The packages of which all classes keep their original
+	// names, which exposes their internal names.
+	KeptPackages []string `json:"keptPackages"`
+	// MethodsWithoutLineNumbers The methods that don't have any line
+	// numbers, like "com.example.Foo: void bar(int)".
+	MethodsWithoutLineNumbers []string `json:"methodsWithoutLineNumbers"`
+}
+
+// ComputeMappingStats Counts the classes and class members of the given
+// mapping, and how many of them keep their original names. Constructors and
+// static initializers are never renamed, so they are not counted.
+func ComputeMappingStats(remapper *FrameRemapper) *MappingStats {
+	inverse := NewInverseFrameRemapper()
+	remapper.Pump(inverse)
+
+	stats := MappingStats{}
+	packages := make(map[string]*PackageStats)
+
+	for _, className := range sortedKeys(inverse.ClassMap) {
+		packageName := ""
+		if dotIndex := strings.LastIndex(className, "."); dotIndex >= 0 {
+			packageName = className[:dotIndex]
+		}
+
+		packageStats, ok := packages[packageName]
+		if !ok {
+			packageStats = &PackageStats{PackageName: packageName}
+			packages[packageName] = packageStats
+		}
+
+		counts := MappingCounts{ClassCount: 1}
+		if inverse.ClassMap[className] == className {
+			counts.UnrenamedClassCount++
+		}
+
+		members := summarizeMembers(inverse, className)
+		for _, signature := range sortedKeys(members) {
+			member := members[signature]
+			unrenamed := len(member.obfuscatedNames) == 1 && member.obfuscatedNames[0] == member.name
+
+			if !member.isMethod {
+				counts.FieldCount++
+				if unrenamed {
+					counts.UnrenamedFieldCount++
+				}
+				continue
+			}
+
+			if member.lastLineNumber == 0 {
+				stats.MethodsWithoutLineNumbers = append(stats.MethodsWithoutLineNumbers, className+": "+signature)
+			}
+
+			if member.name == "<init>" || member.name == "<clinit>" {
+				continue
+			}
+
+			counts.MethodCount++
+			if unrenamed {
+				counts.UnrenamedMethodCount++
+			}
+		}
+
+		packageStats.add(&counts)
+		stats.add(&counts)
+	}
+
+	for _, packageName := range sortedKeys(packages) {
+		packageStats := packages[packageName]
+		stats.Packages = append(stats.Packages, *packageStats)
+
+		if packageStats.UnrenamedClassCount == packageStats.ClassCount {
+			stats.KeptPackages = append(stats.KeptPackages, packageName)
+		}
+	}
+
+	return &stats
+}
+
+// WriteSummary Writes a human readable summary of the statistics.
+func (stats *MappingStats) WriteSummary(writer io.Writer) error {
+	var buffer strings.Builder
+
+	writeCounts := func(indent string, counts *MappingCounts) {
+		fmt.Fprintf(&buffer, "%sClasses: %d (%d unrenamed)\n", indent, counts.ClassCount, counts.UnrenamedClassCount)
+		fmt.Fprintf(&buffer, "%sFields:  %d (%d unrenamed)\n", indent, counts.FieldCount, counts.UnrenamedFieldCount)
+		fmt.Fprintf(&buffer, "%sMethods: %d (%d unrenamed)\n", indent, counts.MethodCount, counts.UnrenamedMethodCount)
+		fmt.Fprintf(&buffer, "%sUnrenamed: %.1f%%\n", indent, 100*counts.UnrenamedShare())
+	}
+
+	writeCounts("", &stats.MappingCounts)
+
+	// List the most readable packages first.
+	packages := make([]PackageStats, len(stats.Packages))
+	copy(packages, stats.Packages)
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].UnrenamedShare() > packages[j].UnrenamedShare()
+	})
+
+	buffer.WriteString("\nPackages:\n")
+	for _, packageStats := range packages {
+		packageName := packageStats.PackageName
+		if len(packageName) == 0 {
+			packageName = "<default>"
+		}
+		fmt.Fprintf(&buffer, "  %s\n", packageName)
+		writeCounts("    ", &packageStats.MappingCounts)
+	}
+
+	if len(stats.KeptPackages) > 0 {
+		buffer.WriteString("\nKept packages:\n")
+		for _, packageName := range stats.KeptPackages {
+			fmt.Fprintf(&buffer, "  %s\n", packageName)
+		}
+	}
+
+	if len(stats.MethodsWithoutLineNumbers) > 0 {
+		buffer.WriteString("\nMethods without line numbers:\n")
+		for _, method := range stats.MethodsWithoutLineNumbers {
+			fmt.Fprintf(&buffer, "  %s\n", method)
+		}
+	}
+
+	_, err := io.WriteString(writer, buffer.String())
+	return err
+}
@@ -0,0 +1,41 @@
+package retrace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeMappingStats(t *testing.T) {
+	remapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(`com.example.Foo -> a.a:
+    int count -> a
+    java.lang.String name -> name
+    1:3:void <init>():5:7 -> <init>
+    1:3:void bar(int):10:12 -> a
+    4:4:void com.example.Baz.helper():30:30 -> a
+    4:4:void bar(int):13 -> a
+    void run() -> run
+com.example.Baz -> a.b:
+    1:1:void helper():30:30 -> a
+J.N -> J.N:
+    void M1() -> M1
+`)).Pump(remapper))
+
+	stats := ComputeMappingStats(remapper)
+	assert.Equal(t, MappingCounts{
+		ClassCount:           3,
+		UnrenamedClassCount:  1,
+		FieldCount:           2,
+		UnrenamedFieldCount:  1,
+		MethodCount:          4,
+		UnrenamedMethodCount: 2,
+	}, stats.MappingCounts)
+	assert.Equal(t, []PackageStats{
+		{"J", MappingCounts{1, 1, 0, 0, 1, 1}},
+		{"com.example", MappingCounts{2, 0, 2, 1, 3, 1}},
+	}, stats.Packages)
+	assert.Equal(t, []string{"J"}, stats.KeptPackages)
+	assert.Equal(t, []string{"J.N: void M1()", "com.example.Foo: void run()"}, stats.MethodsWithoutLineNumbers)
+}
@@ -0,0 +1,426 @@
+package retrace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const mappingStoreManifestName = "mappings.json"
+const mappingStoreObjectsName = "objects"
+const mappingStoreVersion = 1
+
+// ErrMappingNotFound is returned when the store has no mapping that matches
+// a query.
+var ErrMappingNotFound = errors.New("mapping not found")
+
+// MappingStoreEntry A mapping that is registered in a MappingStore.
+type MappingStoreEntry struct {
+	AppID       string `json:"appId"`
+	VersionName string `json:"versionName,omitempty"`
+	VersionCode int    `json:"versionCode,omitempty"`
+	Flavor      string `json:"flavor,omitempty"`
+	// MapID The pg_map_id of the mapping, if it has one.
+	MapID string `json:"mapId,omitempty"`
+	// DebugID The Sentry debug ID of the mapping.
+	DebugID string `json:"debugId"`
+	// Hash The SHA-256 hash of the mapping file, under which its content is
+	// stored.
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Version returns a readable version of the entry, like "1.2.3 (1234)".
+func (entry *MappingStoreEntry) Version() string {
+	if entry.VersionCode == 0 {
+		return entry.VersionName
+	}
+	if len(entry.VersionName) == 0 {
+		return strconv.Itoa(entry.VersionCode)
+	}
+	return fmt.Sprintf("%s (%d)", entry.VersionName, entry.VersionCode)
+}
+
+func (entry *MappingStoreEntry) sameKey(other *MappingStoreEntry) bool {
+	return entry.AppID == other.AppID &&
+		entry.VersionName == other.VersionName &&
+		entry.VersionCode == other.VersionCode &&
+		entry.Flavor == other.Flavor &&
+		entry.MapID == other.MapID
+}
+
+// MappingQuery Selects mappings in a MappingStore. Empty fields match all
+// mappings.
+type MappingQuery struct {
+	AppID string
+	// Version The version name or the version code.
+	Version string
+	Flavor  string
+	// MapID The pg_map_id or the debug ID.
+	MapID string
+}
+
+// Matches returns whether the given entry matches the query.
+func (query *MappingQuery) Matches(entry *MappingStoreEntry) bool {
+	return (len(query.AppID) == 0 || query.AppID == entry.AppID) &&
+		(len(query.Version) == 0 || query.Version == entry.VersionName ||
+			(entry.VersionCode != 0 && query.Version == strconv.Itoa(entry.VersionCode))) &&
+		(len(query.Flavor) == 0 || query.Flavor == entry.Flavor) &&
+		(len(query.MapID) == 0 || query.MapID == entry.MapID || query.MapID == entry.DebugID)
+}
+
+// RetentionPolicy Selects the mappings that MappingStore.Prune removes.
+type RetentionPolicy struct {
+	// KeepLatest The number of latest versions to keep of every app and
+	// flavor, by version code and then by the time they were added, or 0
+	// to keep all of them.
+	KeepLatest int
+	// MaxAge The age after which mappings are removed, or 0 to keep them
+	// regardless of their age. The latest version of every app and flavor
+	// is always kept.
+	MaxAge time.Duration
+}
+
+// MappingStore A directory in which mappings are registered under their app
+// ID, version, build flavor and map ID. The mapping files are stored by
+// the hash of their content, so identical mappings are only stored once.
+//
+// The store is meant to be changed by a single process at a time.
+type MappingStore struct {
+	Root string
+	// Now returns the current time, to register and prune mappings.
+	Now func() time.Time
+}
+
+// mappingStoreManifest The contents of the manifest of a store.
+type mappingStoreManifest struct {
+	Version int                 `json:"version"`
+	Entries []MappingStoreEntry `json:"entries"`
+}
+
+// OpenMappingStore Opens the store in the given directory, and creates the
+// directory if it doesn't exist yet.
+func OpenMappingStore(root string) (*MappingStore, error) {
+	if err := os.MkdirAll(filepath.Join(root, mappingStoreObjectsName), 0o755); err != nil {
+		return nil, err
+	}
+
+	store := MappingStore{
+		Root: root,
+		Now:  time.Now,
+	}
+
+	return &store, nil
+}
+
+// Add Stores the given mapping file under the app ID, version and flavor of
+// the given entry, replacing a mapping with the same key. The map ID is
+// read from the mapping file if the entry doesn't specify it.
+func (store *MappingStore) Add(entry MappingStoreEntry, reader io.Reader) (*MappingStoreEntry, error) {
+	if len(entry.AppID) == 0 {
+		return nil, errors.New("the app ID of the mapping is missing")
+	}
+
+	// Copy the mapping to a temporary file, while hashing it.
+	file, err := os.CreateTemp(filepath.Join(store.Root, mappingStoreObjectsName), "add-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Hash = hex.EncodeToString(hash.Sum(nil))
+	entry.Size = size
+	entry.AddedAt = store.Now().UTC()
+
+	identity, err := store.identity(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	entry.DebugID = identity.DebugID
+	if len(entry.MapID) == 0 {
+		entry.MapID = identity.MapID
+	}
+
+	objectPath := store.ObjectPath(&entry)
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), objectPath); err != nil {
+		return nil, err
+	}
+
+	manifest, err := store.readManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := manifest.Entries[:0]
+	for _, existing := range manifest.Entries {
+		if !existing.sameKey(&entry) {
+			entries = append(entries, existing)
+		}
+	}
+	manifest.Entries = append(entries, entry)
+
+	if err := store.writeManifest(manifest); err != nil {
+		return nil, err
+	}
+
+	// The replaced mapping may not be used anymore.
+	if err := store.removeUnusedObjects(manifest); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// List returns the mappings that match the given query, by app ID, flavor,
+// and then latest version first.
+func (store *MappingStore) List(query MappingQuery) ([]MappingStoreEntry, error) {
+	manifest, err := store.readManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []MappingStoreEntry
+	for _, entry := range manifest.Entries {
+		if query.Matches(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].AppID != entries[j].AppID {
+			return entries[i].AppID < entries[j].AppID
+		}
+		if entries[i].Flavor != entries[j].Flavor {
+			return entries[i].Flavor < entries[j].Flavor
+		}
+		return isNewerEntry(&entries[i], &entries[j])
+	})
+
+	return entries, nil
+}
+
+// Get returns the latest mapping that matches the given query, or
+// ErrMappingNotFound.
+func (store *MappingStore) Get(query MappingQuery) (*MappingStoreEntry, error) {
+	entries, err := store.List(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *MappingStoreEntry
+	for index := range entries {
+		if latest == nil || isNewerEntry(&entries[index], latest) {
+			latest = &entries[index]
+		}
+	}
+
+	if latest == nil {
+		return nil, ErrMappingNotFound
+	}
+
+	return latest, nil
+}
+
+// ObjectPath returns the path of the mapping file of the given entry.
+func (store *MappingStore) ObjectPath(entry *MappingStoreEntry) string {
+	return filepath.Join(store.Root, mappingStoreObjectsName, entry.Hash[:2], entry.Hash+".txt")
+}
+
+// Open Opens the mapping file of the given entry.
+func (store *MappingStore) Open(entry *MappingStoreEntry) (*os.File, error) {
+	return os.Open(store.ObjectPath(entry))
+}
+
+// Delete Removes the mappings that match the given query, and returns them.
+func (store *MappingStore) Delete(query MappingQuery) ([]MappingStoreEntry, error) {
+	return store.remove(func(entry *MappingStoreEntry, rank int) bool {
+		return query.Matches(entry)
+	})
+}
+
+// Prune Removes the mappings that match the given query and that the given
+// retention policy doesn't keep, and returns them.
+func (store *MappingStore) Prune(query MappingQuery, policy RetentionPolicy) ([]MappingStoreEntry, error) {
+	now := store.Now()
+	return store.remove(func(entry *MappingStoreEntry, rank int) bool {
+		if rank == 0 || !query.Matches(entry) {
+			return false
+		}
+		if policy.KeepLatest > 0 && rank >= policy.KeepLatest {
+			return true
+		}
+		return policy.MaxAge > 0 && now.Sub(entry.AddedAt) > policy.MaxAge
+	})
+}
+
+// remove Removes the mappings that the given function selects, with their
+// rank among the mappings of the same app and flavor, latest first, and
+// their files if no other mappings share them.
+func (store *MappingStore) remove(selects func(entry *MappingStoreEntry, rank int) bool) ([]MappingStoreEntry, error) {
+	manifest, err := store.readManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := rankEntries(manifest.Entries)
+
+	var kept []MappingStoreEntry
+	var removed []MappingStoreEntry
+	for index := range manifest.Entries {
+		entry := &manifest.Entries[index]
+		if selects(entry, ranks[index]) {
+			removed = append(removed, *entry)
+		} else {
+			kept = append(kept, *entry)
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	manifest.Entries = kept
+	if err := store.writeManifest(manifest); err != nil {
+		return nil, err
+	}
+
+	if err := store.removeUnusedObjects(manifest); err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// rankEntries returns the rank of every given entry among the entries of the
+// same app and flavor, with 0 for the latest one.
+func rankEntries(entries []MappingStoreEntry) []int {
+	order := make([]int, len(entries))
+	for index := range order {
+		order[index] = index
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return isNewerEntry(&entries[order[i]], &entries[order[j]])
+	})
+
+	ranks := make([]int, len(entries))
+	counts := map[string]int{}
+	for _, index := range order {
+		key := entries[index].AppID + "\x00" + entries[index].Flavor
+		ranks[index] = counts[key]
+		counts[key]++
+	}
+
+	return ranks
+}
+
+// isNewerEntry returns whether the first entry has a later version than the
+// second one, by version code and then by the time it was added.
+func isNewerEntry(entry1 *MappingStoreEntry, entry2 *MappingStoreEntry) bool {
+	if entry1.VersionCode != entry2.VersionCode {
+		return entry1.VersionCode > entry2.VersionCode
+	}
+	return entry1.AddedAt.After(entry2.AddedAt)
+}
+
+// identity returns the identity of the given mapping file.
+func (store *MappingStore) identity(path string) (*MappingIdentity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ComputeMappingIdentity(file)
+}
+
+func (store *MappingStore) readManifest() (*mappingStoreManifest, error) {
+	manifest := mappingStoreManifest{Version: mappingStoreVersion}
+
+	data, err := os.ReadFile(filepath.Join(store.Root, mappingStoreManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return &manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid mapping store manifest: %w", err)
+	}
+	if manifest.Version != mappingStoreVersion {
+		return nil, fmt.Errorf("unsupported mapping store version %d", manifest.Version)
+	}
+
+	return &manifest, nil
+}
+
+// writeManifest Replaces the manifest atomically, so that readers never see
+// a partially written one.
+func (store *MappingStore) writeManifest(manifest *mappingStoreManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(store.Root, mappingStoreManifestName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), filepath.Join(store.Root, mappingStoreManifestName))
+}
+
+// removeUnusedObjects Removes the mapping files that no entry of the given
+// manifest refers to.
+func (store *MappingStore) removeUnusedObjects(manifest *mappingStoreManifest) error {
+	used := map[string]bool{}
+	for index := range manifest.Entries {
+		used[store.ObjectPath(&manifest.Entries[index])] = true
+	}
+
+	paths, err := filepath.Glob(filepath.Join(store.Root, mappingStoreObjectsName, "*", "*.txt"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if !used[path] {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package retrace
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMappingStore(t *testing.T) (*MappingStore, *time.Time) {
+	store, err := OpenMappingStore(t.TempDir())
+	assert.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.Now = func() time.Time { return now }
+
+	return store, &now
+}
+
+func TestMappingStoreAddAndGet(t *testing.T) {
+	store, now := newTestMappingStore(t)
+
+	entry, err := store.Add(MappingStoreEntry{AppID: "com.foo", VersionName: "1.0", VersionCode: 10}, strings.NewReader(identityMappingData))
+	assert.NoError(t, err)
+	assert.Equal(t, "fdcbdac", entry.MapID)
+	assert.Equal(t, "3a624463-b5af-532d-9dc8-d6108df93afd", entry.DebugID)
+	assert.Equal(t, "1.0 (10)", entry.Version())
+
+	*now = now.Add(time.Hour)
+	_, err = store.Add(MappingStoreEntry{AppID: "com.foo", VersionName: "1.1", VersionCode: 11}, strings.NewReader("com.example.Foo -> b:\n"))
+	assert.NoError(t, err)
+	_, err = store.Add(MappingStoreEntry{AppID: "com.bar", VersionCode: 10}, strings.NewReader(identityMappingData))
+	assert.NoError(t, err)
+
+	// Queries match the version name or code, and the map ID or debug ID.
+	for _, query := range []MappingQuery{
+		{AppID: "com.foo", Version: "10"},
+		{AppID: "com.foo", Version: "1.0"},
+		{AppID: "com.foo", MapID: "fdcbdac"},
+		{AppID: "com.foo", MapID: "3a624463-b5af-532d-9dc8-d6108df93afd"},
+	} {
+		found, err := store.Get(query)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, found.VersionCode)
+	}
+
+	// Without a version, the latest version matches.
+	found, err := store.Get(MappingQuery{AppID: "com.foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, 11, found.VersionCode)
+
+	file, err := store.Open(found)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, "com.example.Foo -> b:\n", string(data))
+
+	_, err = store.Get(MappingQuery{AppID: "com.foo", Version: "12"})
+	assert.ErrorIs(t, err, ErrMappingNotFound)
+
+	entries, err := store.List(MappingQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "com.bar", entries[0].AppID)
+	assert.Equal(t, 11, entries[1].VersionCode)
+
+	// Identical mappings share their file.
+	assert.Equal(t, store.ObjectPath(&entries[0]), store.ObjectPath(&entries[2]))
+}
+
+func TestMappingStoreDelete(t *testing.T) {
+	store, _ := newTestMappingStore(t)
+
+	entry1, _ := store.Add(MappingStoreEntry{AppID: "com.foo", VersionCode: 1}, strings.NewReader(identityMappingData))
+	entry2, _ := store.Add(MappingStoreEntry{AppID: "com.bar", VersionCode: 1}, strings.NewReader(identityMappingData))
+	entry3, _ := store.Add(MappingStoreEntry{AppID: "com.bar", VersionCode: 2}, strings.NewReader("com.example.Foo -> b:\n"))
+
+	removed, err := store.Delete(MappingQuery{AppID: "com.bar"})
+	assert.NoError(t, err)
+	assert.Len(t, removed, 2)
+
+	// The shared file is still used, the other one is removed.
+	_, err = os.Stat(store.ObjectPath(entry1))
+	assert.NoError(t, err)
+	_, err = os.Stat(store.ObjectPath(entry2))
+	assert.NoError(t, err)
+	_, err = os.Stat(store.ObjectPath(entry3))
+	assert.True(t, os.IsNotExist(err))
+
+	entries, _ := store.List(MappingQuery{})
+	assert.Len(t, entries, 1)
+}
+
+func TestMappingStorePrune(t *testing.T) {
+	store, now := newTestMappingStore(t)
+
+	for versionCode := 1; versionCode <= 4; versionCode++ {
+		_, err := store.Add(MappingStoreEntry{AppID: "com.foo", VersionCode: versionCode}, strings.NewReader(generateMapping(versionCode)))
+		assert.NoError(t, err)
+		*now = now.Add(24 * time.Hour)
+	}
+	_, err := store.Add(MappingStoreEntry{AppID: "com.foo", Flavor: "free", VersionCode: 1}, strings.NewReader(identityMappingData))
+	assert.NoError(t, err)
+
+	removed, err := store.Prune(MappingQuery{}, RetentionPolicy{KeepLatest: 3})
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	assert.Equal(t, 1, removed[0].VersionCode)
+	assert.Equal(t, "", removed[0].Flavor)
+
+	// The latest version of every flavor is kept, however old.
+	*now = now.Add(365 * 24 * time.Hour)
+	removed, err = store.Prune(MappingQuery{}, RetentionPolicy{MaxAge: 30 * 24 * time.Hour})
+	assert.NoError(t, err)
+	assert.Len(t, removed, 2)
+
+	entries, _ := store.List(MappingQuery{})
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 4, entries[0].VersionCode)
+	assert.Equal(t, "free", entries[1].Flavor)
+}
+
+func TestMappingStorePruneQuery(t *testing.T) {
+	store, _ := newTestMappingStore(t)
+
+	for _, appID := range []string{"com.foo", "com.bar"} {
+		for versionCode := 1; versionCode <= 3; versionCode++ {
+			_, err := store.Add(MappingStoreEntry{AppID: appID, VersionCode: versionCode}, strings.NewReader(generateMapping(versionCode)))
+			assert.NoError(t, err)
+		}
+	}
+
+	// Only the mappings of the selected app are pruned.
+	removed, err := store.Prune(MappingQuery{AppID: "com.foo"}, RetentionPolicy{KeepLatest: 1})
+	assert.NoError(t, err)
+	assert.Len(t, removed, 2)
+	for _, entry := range removed {
+		assert.Equal(t, "com.foo", entry.AppID)
+	}
+
+	entries, _ := store.List(MappingQuery{AppID: "com.bar"})
+	assert.Len(t, entries, 3)
+	entries, _ = store.List(MappingQuery{AppID: "com.foo"})
+	assert.Len(t, entries, 1)
+	assert.Equal(t, 3, entries[0].VersionCode)
+}
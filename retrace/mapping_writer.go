@@ -0,0 +1,140 @@
+package retrace
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// MappingWriter This MappingProcessor writes the mappings it receives in the
+// ProGuard/R8 mapping file format, which MappingReader can read back.
+//
+// Inlined methods stay grouped as long as they are received in order, and
+// comments are written back at the position where they were received.
+//
+// Usage:
+//
+//	writer := NewMappingWriter(output)
+//	remapper.Pump(writer)
+//	err := writer.Flush()
+type MappingWriter struct {
+	writer *bufio.Writer
+
+	// Whether a class member has been written since the last class mapping.
+	inClassMembers bool
+}
+
+func NewMappingWriter(writer io.Writer) *MappingWriter {
+	mappingWriter := MappingWriter{
+		writer: bufio.NewWriter(writer),
+	}
+
+	return &mappingWriter
+}
+
+// Flush Writes any buffered data to the underlying writer, and returns the
+// first error that occurred while writing.
+func (w *MappingWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *MappingWriter) ProcessClassMapping(className string, newClassName string) bool {
+	// "___ -> ___:"
+	w.writer.WriteString(className)
+	w.writer.WriteString(" -> ")
+	w.writer.WriteString(newClassName)
+	w.writer.WriteString(":\n")
+
+	w.inClassMembers = false
+	return true
+}
+
+func (w *MappingWriter) ProcessFieldMapping(
+	className string,
+	fieldType string,
+	fieldName string,
+	newClassName string,
+	newFieldName string) {
+
+	// "    ___ ___ -> ___"
+	w.writer.WriteString("    ")
+	w.writer.WriteString(fieldType)
+	w.writer.WriteString(" ")
+	w.writeMemberName(className, newClassName, fieldName)
+	w.writer.WriteString(" -> ")
+	w.writer.WriteString(newFieldName)
+	w.writer.WriteString("\n")
+
+	w.inClassMembers = true
+}
+
+func (w *MappingWriter) ProcessMethodMapping(
+	className string,
+	firstLineNumber int,
+	lastLineNumber int,
+	methodType string,
+	methodName string,
+	arguments string,
+	newClassName string,
+	newFirstLineNumber int,
+	newLastLineNumber int,
+	newMethodName string) {
+
+	// "    ___:___:___ ___(___):___:___ -> ___"
+	w.writer.WriteString("    ")
+
+	// The mapping format only has room for the original line numbers if
+	// there are new line numbers.
+	hasLineNumbers := newFirstLineNumber != 0 || newLastLineNumber != 0
+	if hasLineNumbers {
+		w.writer.WriteString(strconv.Itoa(newFirstLineNumber))
+		w.writer.WriteString(":")
+		w.writer.WriteString(strconv.Itoa(newLastLineNumber))
+		w.writer.WriteString(":")
+	}
+
+	w.writer.WriteString(methodType)
+	w.writer.WriteString(" ")
+	w.writeMemberName(className, newClassName, methodName)
+	w.writer.WriteString("(")
+	w.writer.WriteString(arguments)
+	w.writer.WriteString(")")
+
+	if hasLineNumbers && (firstLineNumber != 0 || lastLineNumber != 0) {
+		w.writer.WriteString(":")
+		w.writer.WriteString(strconv.Itoa(firstLineNumber))
+
+		// A single original line for a range of new lines, typically the
+		// line of an inlined call, is written as ":___".
+		if firstLineNumber != lastLineNumber || newFirstLineNumber == newLastLineNumber {
+			w.writer.WriteString(":")
+			w.writer.WriteString(strconv.Itoa(lastLineNumber))
+		}
+	}
+
+	w.writer.WriteString(" -> ")
+	w.writer.WriteString(newMethodName)
+	w.writer.WriteString("\n")
+
+	w.inClassMembers = true
+}
+
+func (w *MappingWriter) ProcessComment(comment string) {
+	// R8 indents the metadata of class members, like the members themselves.
+	if w.inClassMembers {
+		w.writer.WriteString("    ")
+	}
+	w.writer.WriteString("# ")
+	w.writer.WriteString(comment)
+	w.writer.WriteString("\n")
+}
+
+// writeMemberName Writes the member name, prefixed with its original class
+// name if the member was inlined from another class.
+func (w *MappingWriter) writeMemberName(className string, newClassName string, memberName string) {
+	if className != newClassName {
+		w.writer.WriteString(className)
+		w.writer.WriteString(".")
+	}
+	w.writer.WriteString(memberName)
+}
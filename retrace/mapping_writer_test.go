@@ -0,0 +1,60 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMappingWriter(t *testing.T) {
+	mapping := `# compiler: R8
+# pg_map_id: 1a2b3c4
+com.example.Foo -> a:
+# {"id":"sourceFile","fileName":"Foo.kt"}
+    int count -> a
+    java.lang.String com.example.Bar.name -> b
+    1:3:void <init>():10:12 -> <init>
+    4:4:void com.example.Bar.run(int,java.lang.String):42:42 -> b
+    4:4:void start():20:20 -> b
+    5:6:void start():21 -> b
+    void stop() -> c
+    # {"id":"com.android.tools.r8.synthesized"}
+com.example.Bar -> b:
+    1:1:void run(int,java.lang.String):41:41 -> a
+`
+
+	output := bytes.NewBufferString("")
+	writer := NewMappingWriter(output)
+	assert.NoError(t, NewMappingReader(strings.NewReader(mapping)).Pump(writer))
+	assert.NoError(t, writer.Flush())
+
+	assert.Equal(t, mapping, output.String())
+}
+
+func TestMappingWriterFrameRemapper(t *testing.T) {
+	remapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(mappingData)).Pump(remapper))
+
+	output := bytes.NewBufferString("")
+	writer := NewMappingWriter(output)
+	remapper.Pump(writer)
+	assert.NoError(t, writer.Flush())
+
+	// Reading the written mapping back gives the same mapping.
+	rereadRemapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(output.String())).Pump(rereadRemapper))
+	assert.Equal(t, remapper.ClassMap, rereadRemapper.ClassMap)
+	assert.Equal(t, remapper.HeaderComments, rereadRemapper.HeaderComments)
+
+	frame := FrameInfo{ClassName: "f", MethodName: "remove", LineNumber: 3}
+	assert.Equal(t, remapper.Transform(&frame), rereadRemapper.Transform(&frame))
+
+	// Writing it again gives the same output.
+	rewritten := bytes.NewBufferString("")
+	rewriter := NewMappingWriter(rewritten)
+	rereadRemapper.Pump(rewriter)
+	assert.NoError(t, rewriter.Flush())
+	assert.Equal(t, output.String(), rewritten.String())
+}
@@ -0,0 +1,191 @@
+package retrace
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// Sets with more entries than this also keep a map to find duplicates,
+// rather than comparing all entries.
+const memberInfoSetIndexThreshold = 8
+
+// FieldInfoSet A set of fields with the same obfuscated name, in the order in
+// which they were added.
+type FieldInfoSet struct {
+	values []FieldInfo
+	index  map[FieldInfo]struct{}
+}
+
+// Add Adds the given field, unless the set already contains it, and returns
+// whether it was added.
+func (set *FieldInfoSet) Add(fieldInfo FieldInfo) bool {
+	if set.index != nil {
+		if _, ok := set.index[fieldInfo]; ok {
+			return false
+		}
+	} else {
+		for _, value := range set.values {
+			if value == fieldInfo {
+				return false
+			}
+		}
+	}
+
+	set.values = append(set.values, fieldInfo)
+	if set.index != nil {
+		set.index[fieldInfo] = struct{}{}
+	} else if len(set.values) > memberInfoSetIndexThreshold {
+		set.index = make(map[FieldInfo]struct{}, len(set.values))
+		for _, value := range set.values {
+			set.index[value] = struct{}{}
+		}
+	}
+
+	return true
+}
+
+// Size returns the number of fields in the set.
+func (set *FieldInfoSet) Size() int {
+	return len(set.values)
+}
+
+// Values returns the fields in the order in which they were added. The
+// returned slice must not be modified.
+func (set *FieldInfoSet) Values() []FieldInfo {
+	return set.values
+}
+
+// MethodInfoSet A set of methods with the same obfuscated name, in the order
+// in which they were added, which is the order of the mapping file that keeps
+// the inlined methods together. It can find the methods at an obfuscated line
+// number with a binary search.
+type MethodInfoSet struct {
+	values []MethodInfo
+	index  map[MethodInfo]struct{}
+
+	// Computed when it's first needed after a change, atomically, so
+	// lookups can run concurrently.
+	ranges atomic.Pointer[methodRangeIndex]
+}
+
+// methodRangeIndex The indices of the methods of a MethodInfoSet, sorted by
+// their obfuscated first line numbers, and for each of them the maximum
+// obfuscated last line number up to it.
+type methodRangeIndex struct {
+	order             []int32
+	maxLastLineNumber []int
+}
+
+// Add Adds the given method, unless the set already contains it, and returns
+// whether it was added.
+func (set *MethodInfoSet) Add(methodInfo MethodInfo) bool {
+	if set.index != nil {
+		if _, ok := set.index[methodInfo]; ok {
+			return false
+		}
+	} else {
+		for _, value := range set.values {
+			if value == methodInfo {
+				return false
+			}
+		}
+	}
+
+	set.values = append(set.values, methodInfo)
+	if set.index != nil {
+		set.index[methodInfo] = struct{}{}
+	} else if len(set.values) > memberInfoSetIndexThreshold {
+		set.index = make(map[MethodInfo]struct{}, len(set.values))
+		for _, value := range set.values {
+			set.index[value] = struct{}{}
+		}
+	}
+
+	set.ranges.Store(nil)
+	return true
+}
+
+// Size returns the number of methods in the set.
+func (set *MethodInfoSet) Size() int {
+	return len(set.values)
+}
+
+// Values returns the methods in the order in which they were added. The
+// returned slice must not be modified.
+func (set *MethodInfoSet) Values() []MethodInfo {
+	return set.values
+}
+
+// ValuesAt returns the methods whose obfuscated line ranges contain the given
+// obfuscated line number or are unknown, in the order in which they were
+// added. It returns all methods if the line number is 0.
+func (set *MethodInfoSet) ValuesAt(obfuscatedLineNumber int) []MethodInfo {
+	if obfuscatedLineNumber <= 0 || len(set.values) <= memberInfoSetIndexThreshold {
+		var methodInfos []MethodInfo
+		for _, methodInfo := range set.values {
+			if methodInfo.Matches(obfuscatedLineNumber, "", "") {
+				methodInfos = append(methodInfos, methodInfo)
+			}
+		}
+		return methodInfos
+	}
+
+	ranges := set.rangeIndex()
+
+	// The ranges that can contain the line number start at or before it,
+	// and only extend to it if the maximum last line number does.
+	end := sort.Search(len(ranges.order), func(i int) bool {
+		return set.values[ranges.order[i]].ObfuscatedFirstLineNumber > obfuscatedLineNumber
+	})
+
+	var indices []int
+	for i := end - 1; i >= 0 && ranges.maxLastLineNumber[i] >= obfuscatedLineNumber; i-- {
+		index := int(ranges.order[i])
+		if set.values[index].Matches(obfuscatedLineNumber, "", "") {
+			indices = append(indices, index)
+		}
+	}
+
+	// The methods with unknown ranges sort first, with first line number 0,
+	// and match as well, if the loop above didn't reach them.
+	for i := 0; i < end && set.values[ranges.order[i]].ObfuscatedLastLineNumber == 0; i++ {
+		if ranges.maxLastLineNumber[i] >= obfuscatedLineNumber {
+			break
+		}
+		indices = append(indices, int(ranges.order[i]))
+	}
+
+	sort.Ints(indices)
+	methodInfos := make([]MethodInfo, len(indices))
+	for i, index := range indices {
+		methodInfos[i] = set.values[index]
+	}
+	return methodInfos
+}
+
+func (set *MethodInfoSet) rangeIndex() *methodRangeIndex {
+	if ranges := set.ranges.Load(); ranges != nil {
+		return ranges
+	}
+
+	order := make([]int32, len(set.values))
+	for i := range order {
+		order[i] = int32(i)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return set.values[order[i]].ObfuscatedFirstLineNumber < set.values[order[j]].ObfuscatedFirstLineNumber
+	})
+
+	maxLastLineNumber := make([]int, len(order))
+	max := 0
+	for i, index := range order {
+		if lastLineNumber := set.values[index].ObfuscatedLastLineNumber; lastLineNumber > max {
+			max = lastLineNumber
+		}
+		maxLastLineNumber[i] = max
+	}
+
+	ranges := &methodRangeIndex{order: order, maxLastLineNumber: maxLastLineNumber}
+	set.ranges.Store(ranges)
+	return ranges
+}
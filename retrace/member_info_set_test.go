@@ -0,0 +1,65 @@
+package retrace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldInfoSet(t *testing.T) {
+	set := FieldInfoSet{}
+	for i := 0; i < 2; i++ {
+		for _, name := range []string{"b", "a", "c", "d", "e", "f", "g", "h", "i", "j"} {
+			set.Add(FieldInfo{OriginalClassName: "com.example.Foo", OriginalType: "int", OriginalName: name})
+		}
+	}
+
+	// Duplicates are left out, also once the set keeps a map.
+	assert.Equal(t, 10, set.Size())
+	assert.False(t, set.Add(FieldInfo{OriginalClassName: "com.example.Foo", OriginalType: "int", OriginalName: "b"}))
+	assert.Equal(t, "b", set.Values()[0].OriginalName)
+	assert.Equal(t, "a", set.Values()[1].OriginalName)
+}
+
+func TestMethodInfoSet(t *testing.T) {
+	methodInfo := func(first, last int, name string) MethodInfo {
+		return MethodInfo{
+			ObfuscatedFirstLineNumber: first,
+			ObfuscatedLastLineNumber:  last,
+			OriginalClassName:         "com.example.Foo",
+			OriginalType:              "void",
+			OriginalName:              name,
+		}
+	}
+
+	set := MethodInfoSet{}
+	for _, info := range []MethodInfo{
+		methodInfo(20, 25, "e"),
+		methodInfo(1, 3, "a"),
+		methodInfo(4, 4, "inlined"),
+		methodInfo(4, 4, "b"),
+		methodInfo(0, 0, "unknown"),
+		methodInfo(5, 30, "wide"),
+		methodInfo(6, 7, "c"),
+		methodInfo(8, 8, "d"),
+		methodInfo(31, 31, "f"),
+		methodInfo(4, 4, "b"),
+	} {
+		set.Add(info)
+	}
+	assert.Equal(t, 9, set.Size())
+
+	for lineNumber := -1; lineNumber <= 33; lineNumber++ {
+		var expected []MethodInfo
+		for _, info := range set.Values() {
+			if info.Matches(lineNumber, "", "") {
+				expected = append(expected, info)
+			}
+		}
+		assert.Equal(t, expected, set.ValuesAt(lineNumber), "line %d", lineNumber)
+	}
+
+	// Adding a method updates the ranges.
+	set.Add(methodInfo(32, 33, "g"))
+	assert.Equal(t, []MethodInfo{methodInfo(0, 0, "unknown"), methodInfo(32, 33, "g")}, set.ValuesAt(33))
+}
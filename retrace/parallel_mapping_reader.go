@@ -0,0 +1,270 @@
+package retrace
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Each worker parses about this many chunks, so that the work is spread
+// evenly even if some parts of the mapping are slower to parse.
+const chunksPerWorker = 4
+
+// Mappings smaller than this are parsed in a single chunk.
+const minChunkSize = 64 * 1024
+
+// NewParallelMappingReader Creates a MappingReader that parses the mapping
+// on the given number of goroutines. It reads the entire mapping into memory
+// and splits it into chunks at class mappings, parses the chunks
+// concurrently, and passes their mappings to the processor in the order of
+// the mapping file, so the processor sees the same mappings as with a
+// sequential MappingReader, on a single goroutine.
+func NewParallelMappingReader(fileReader io.Reader, workers int) *MappingReader {
+	reader := NewMappingReader(fileReader)
+	reader.Workers = workers
+
+	return reader
+}
+
+// mappingEventKind The kind of a recorded mapping.
+type mappingEventKind byte
+
+const (
+	classMappingEvent mappingEventKind = iota
+	fieldMappingEvent
+	methodMappingEvent
+	commentEvent
+)
+
+// mappingEvent A mapping that mappingRecorder recorded, with the arguments
+// of the corresponding MappingProcessor method.
+type mappingEvent struct {
+	kind mappingEventKind
+
+	className    string
+	memberType   string
+	memberName   string
+	arguments    string
+	newClassName string
+	newName      string
+
+	firstLineNumber    int
+	lastLineNumber     int
+	newFirstLineNumber int
+	newLastLineNumber  int
+
+	comment string
+}
+
+// mappingRecorder This MappingProcessor records all mappings of a chunk of a
+// mapping file, to replay them later.
+type mappingRecorder struct {
+	events []mappingEvent
+}
+
+func (recorder *mappingRecorder) ProcessClassMapping(className string, newClassName string) bool {
+	recorder.events = append(recorder.events, mappingEvent{
+		kind:         classMappingEvent,
+		className:    className,
+		newClassName: newClassName,
+	})
+
+	// Record the class members, in case the processor is interested.
+	return true
+}
+
+func (recorder *mappingRecorder) ProcessFieldMapping(
+	className string,
+	fieldType string,
+	fieldName string,
+	newClassName string,
+	newFieldName string) {
+
+	recorder.events = append(recorder.events, mappingEvent{
+		kind:         fieldMappingEvent,
+		className:    className,
+		memberType:   fieldType,
+		memberName:   fieldName,
+		newClassName: newClassName,
+		newName:      newFieldName,
+	})
+}
+
+func (recorder *mappingRecorder) ProcessMethodMapping(
+	className string,
+	firstLineNumber int,
+	lastLineNumber int,
+	methodType string,
+	methodName string,
+	arguments string,
+	newClassName string,
+	newFirstLineNumber int,
+	newLastLineNumber int,
+	newMethodName string) {
+
+	recorder.events = append(recorder.events, mappingEvent{
+		kind:               methodMappingEvent,
+		className:          className,
+		firstLineNumber:    firstLineNumber,
+		lastLineNumber:     lastLineNumber,
+		memberType:         methodType,
+		memberName:         methodName,
+		arguments:          arguments,
+		newClassName:       newClassName,
+		newFirstLineNumber: newFirstLineNumber,
+		newLastLineNumber:  newLastLineNumber,
+		newName:            newMethodName,
+	})
+}
+
+func (recorder *mappingRecorder) ProcessComment(comment string) {
+	recorder.events = append(recorder.events, mappingEvent{
+		kind:    commentEvent,
+		comment: comment,
+	})
+}
+
+// pumpParallel Parses the mapping in chunks on several goroutines, and
+// replays the mappings of the chunks in order to the given processor.
+func (r *MappingReader) pumpParallel(processor MappingProcessor) error {
+	data, err := io.ReadAll(r.fileReader)
+	if err != nil {
+		return err
+	}
+
+	// The chunks share the data, rather than copying it.
+	chunks := splitMapping(data, r.Workers*chunksPerWorker)
+
+	// Parse the chunks.
+	recorders := make([]mappingRecorder, len(chunks))
+	errs := make([]error, len(chunks))
+	done := make([]chan struct{}, len(chunks))
+	for index := range done {
+		done[index] = make(chan struct{})
+	}
+
+	next := make(chan int, len(chunks))
+	for index := range chunks {
+		next <- index
+	}
+	close(next)
+
+	var workers sync.WaitGroup
+	for worker := 0; worker < r.Workers && worker < len(chunks); worker++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range next {
+				errs[index] = NewMappingReader(bytes.NewReader(chunks[index])).Pump(&recorders[index])
+				close(done[index])
+			}
+		}()
+	}
+
+	// Replay the chunks as soon as they are parsed, in order, with the same
+	// rules for uninteresting classes and comments as Pump.
+	commentProcessor, _ := processor.(MappingCommentProcessor)
+	seenClassMapping := false
+	interested := false
+	for index := range chunks {
+		<-done[index]
+		if errs[index] != nil {
+			// Let the workers finish before returning.
+			workers.Wait()
+			return errs[index]
+		}
+
+		for _, event := range recorders[index].events {
+			switch event.kind {
+			case classMappingEvent:
+				seenClassMapping = true
+				interested = processor.ProcessClassMapping(event.className, event.newClassName)
+			case fieldMappingEvent:
+				if interested {
+					processor.ProcessFieldMapping(event.className, event.memberType, event.memberName, event.newClassName, event.newName)
+				}
+			case methodMappingEvent:
+				if interested {
+					processor.ProcessMethodMapping(
+						event.className,
+						event.firstLineNumber,
+						event.lastLineNumber,
+						event.memberType,
+						event.memberName,
+						event.arguments,
+						event.newClassName,
+						event.newFirstLineNumber,
+						event.newLastLineNumber,
+						event.newName,
+					)
+				}
+			case commentEvent:
+				if commentProcessor != nil && (!seenClassMapping || interested) {
+					commentProcessor.ProcessComment(event.comment)
+				}
+			}
+		}
+
+		// Release the mappings of the chunk.
+		recorders[index].events = nil
+	}
+
+	workers.Wait()
+	return nil
+}
+
+// splitMapping Splits the given mapping into about the given number of
+// chunks, at lines with class mappings, so that every chunk starts with a
+// class mapping, except for the first one.
+func splitMapping(data []byte, chunkCount int) [][]byte {
+	chunkSize := len(data) / chunkCount
+	if chunkSize < minChunkSize {
+		chunkSize = minChunkSize
+	}
+
+	var chunks [][]byte
+	start := 0
+	for start < len(data) {
+		end := start + chunkSize
+		if end >= len(data) {
+			chunks = append(chunks, data[start:])
+			break
+		}
+
+		end = nextClassMappingLine(data, end)
+		chunks = append(chunks, data[start:end])
+		start = end
+	}
+
+	return chunks
+}
+
+// nextClassMappingLine returns the offset of the first line at or after the
+// line after the given offset that contains a class mapping, or the length of
+// the data if there is none. Class mappings are the only lines that aren't
+// indented, and that end with a colon.
+func nextClassMappingLine(data []byte, offset int) int {
+	for {
+		newlineIndex := bytes.IndexByte(data[offset:], '\n')
+		if newlineIndex < 0 {
+			return len(data)
+		}
+
+		lineStart := offset + newlineIndex + 1
+		lineEnd := bytes.IndexByte(data[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(data)
+		} else {
+			lineEnd += lineStart
+		}
+
+		line := data[lineStart:lineEnd]
+		if len(line) > 0 &&
+			line[0] != ' ' && line[0] != '\t' && line[0] != '#' &&
+			bytes.HasSuffix(bytes.TrimSpace(line), []byte(":")) {
+			return lineStart
+		}
+
+		offset = lineStart
+	}
+}
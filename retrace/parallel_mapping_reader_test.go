@@ -0,0 +1,67 @@
+package retrace
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// generateMapping returns a mapping with the given number of classes, with
+// fields, inlined methods and comments.
+func generateMapping(classCount int) string {
+	var buffer strings.Builder
+	buffer.WriteString("# compiler: R8\n")
+	for i := 0; i < classCount; i++ {
+		fmt.Fprintf(&buffer, "com.example.p%d.Class%d -> a%d:\n", i%7, i, i)
+		fmt.Fprintf(&buffer, "# {\"id\":\"sourceFile\",\"fileName\":\"Class%d.kt\"}\n", i)
+		fmt.Fprintf(&buffer, "    int count%d -> a\n", i)
+		fmt.Fprintf(&buffer, "    1:3:void run(int):10:12 -> a\n")
+		fmt.Fprintf(&buffer, "    4:4:void com.example.Util.log():5:5 -> b\n")
+		fmt.Fprintf(&buffer, "    4:4:void run(int):13 -> b\n")
+		fmt.Fprintf(&buffer, "    # {\"id\":\"com.android.tools.r8.synthesized\"}\n")
+		fmt.Fprintf(&buffer, "    void stop() -> c\n")
+	}
+	return buffer.String()
+}
+
+func TestParallelMappingReader(t *testing.T) {
+	mapping := generateMapping(3000)
+	assert.Greater(t, len(splitMapping([]byte(mapping), 16)), 4)
+
+	pump := func(reader *MappingReader, classNames []string) string {
+		output := bytes.NewBufferString("")
+		writer := NewMappingWriter(output)
+		var processor MappingProcessor = writer
+		if classNames != nil {
+			processor = NewMappingFilter(writer, classNames, nil)
+		}
+		assert.NoError(t, reader.Pump(processor))
+		assert.NoError(t, writer.Flush())
+		return output.String()
+	}
+
+	expected := pump(NewMappingReader(strings.NewReader(mapping)), nil)
+	assert.Equal(t, expected, pump(NewParallelMappingReader(strings.NewReader(mapping), 4), nil))
+
+	// Uninteresting classes are skipped with their members and comments.
+	classNames := []string{"com.example.p3"}
+	expected = pump(NewMappingReader(strings.NewReader(mapping)), classNames)
+	assert.Equal(t, expected, pump(NewParallelMappingReader(strings.NewReader(mapping), 4), classNames))
+}
+
+func TestSplitMapping(t *testing.T) {
+	mapping := generateMapping(3000)
+	chunks := splitMapping([]byte(mapping), 8)
+
+	assert.Equal(t, []byte(mapping), bytes.Join(chunks, nil))
+	assert.True(t, bytes.HasPrefix(chunks[0], []byte("# compiler: R8\n")))
+	for _, chunk := range chunks[1:] {
+		assert.True(t, bytes.HasPrefix(chunk, []byte("com.example.")), string(chunk[:40]))
+	}
+
+	// Small mappings aren't split.
+	assert.Equal(t, [][]byte{[]byte(mappingData)}, splitMapping([]byte(mappingData), 8))
+}
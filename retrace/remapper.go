@@ -0,0 +1,197 @@
+package retrace
+
+import (
+	"strings"
+)
+
+// Remapper This interface specifies the lookups that Retrace needs to remap
+// obfuscated stack frames to their original frames. FrameRemapper implements
+// it with the mappings in memory, and MappingIndex with the mappings in a
+// binary index.
+type Remapper interface {
+	// GetOriginalClassName returns the original name of the given obfuscated
+	// class, or the name itself if it wasn't obfuscated.
+	GetOriginalClassName(obfuscatedClassName string) string
+
+	// Transform Transforms the given obfuscated frame into one or more
+	// original frames.
+	Transform(obfuscatedFrame *FrameInfo) []FrameInfo
+}
+
+// mappingLookup The lookups on which transformFrame is built.
+type mappingLookup interface {
+	GetOriginalClassName(obfuscatedClassName string) string
+
+	// hasClass returns whether the mapping contains the given obfuscated
+	// class.
+	hasClass(obfuscatedClassName string) bool
+
+	// fieldInfos returns the fields of the given original class with the
+	// given obfuscated name.
+	fieldInfos(originalClassName string, obfuscatedFieldName string) []FieldInfo
+
+	// methodInfos returns the methods of the given original class with the
+	// given obfuscated name, in the order of the mapping file. If the
+	// obfuscated line number isn't 0, it may leave out the methods that
+	// don't match it.
+	methodInfos(originalClassName string, obfuscatedMethodName string, obfuscatedLineNumber int) []MethodInfo
+}
+
+func transformFrame(lookup mappingLookup, obfuscatedFrame *FrameInfo) []FrameInfo {
+	// First remap the class name.
+	originalClassName := lookup.GetOriginalClassName(obfuscatedFrame.ClassName)
+
+	// Create any transformed frames with remapped field names.
+	var originalFrames []FrameInfo
+	originalFrames = transformFieldInfo(lookup, *obfuscatedFrame, originalClassName, originalFrames)
+
+	// Create any transformed frames with remapped method names.
+	originalFrames = transformMethodInfo(lookup, *obfuscatedFrame, originalClassName, originalFrames)
+
+	if len(originalFrames) == 0 {
+		// No remapping was possible, so just use the original frame.
+		var sourceFile string = obfuscatedFrame.SourceFile
+		if len(sourceFile) == 0 && sourceFile != "Unknown Source" && sourceFile != "Native Method" {
+			sourceFile = getSourceFileName(originalClassName)
+		}
+
+		// The types may still refer to obfuscated classes, for example in
+		// the descriptor of a library method.
+		originalType := obfuscatedFrame.Type
+		if len(originalType) > 0 {
+			originalType = getOriginalType(lookup, originalType)
+		}
+		originalArguments := obfuscatedFrame.Arguments
+		if len(originalArguments) > 0 {
+			originalArguments = getOriginalArguments(lookup, originalArguments)
+		}
+
+		originalFrames = append(originalFrames, FrameInfo{
+			originalClassName,
+			sourceFile,
+			obfuscatedFrame.LineNumber,
+			originalType,
+			obfuscatedFrame.FieldName,
+			obfuscatedFrame.MethodName,
+			originalArguments,
+		})
+	}
+
+	return originalFrames
+}
+
+/**
+ * transformFieldInfo
+ * Transforms the obfuscated frame into one or more original frames,
+ * if the frame contains information about a field that can be remapped.
+ * @param obfuscatedFrame     the obfuscated frame.
+ * @param originalFieldFrames the list in which remapped frames can be
+ *                            collected.
+ */
+func transformFieldInfo(lookup mappingLookup, obfuscatedFrame FrameInfo, originalClassName string, originalFieldFrames []FrameInfo) []FrameInfo {
+	// Obfuscated field names -> fields
+	fieldInfos := lookup.fieldInfos(originalClassName, obfuscatedFrame.FieldName)
+	if len(fieldInfos) == 0 {
+		return originalFieldFrames
+	}
+
+	originalType := getOriginalType(lookup, obfuscatedFrame.Type)
+
+	// Find all matching fields
+	for _, fieldInfo := range fieldInfos {
+		if !fieldInfo.Matches(originalType) {
+			continue
+		}
+
+		originalFieldFrames = append(originalFieldFrames, FrameInfo{
+			fieldInfo.OriginalClassName,
+			getSourceFileName(fieldInfo.OriginalClassName),
+			obfuscatedFrame.LineNumber,
+			fieldInfo.OriginalType,
+			fieldInfo.OriginalName,
+			obfuscatedFrame.MethodName,
+			obfuscatedFrame.Arguments,
+		})
+	}
+
+	return originalFieldFrames
+}
+
+/**
+ * transformMethodInfo
+ * Transforms the obfuscated frame into one or more original frames,
+ * if the frame contains information about a method that can be remapped.
+ * @param obfuscatedFrame      the obfuscated frame.
+ * @param originalMethodFrames the list in which remapped frames can be
+ *                             collected.
+ */
+func transformMethodInfo(lookup mappingLookup, obfuscatedFrame FrameInfo, originalClassName string, originalMethodFrames []FrameInfo) []FrameInfo {
+	// Obfuscated method names -> methods
+	methodInfos := lookup.methodInfos(originalClassName, obfuscatedFrame.MethodName, obfuscatedFrame.LineNumber)
+	if len(methodInfos) == 0 {
+		return originalMethodFrames
+	}
+
+	obfuscatedLineNumber := obfuscatedFrame.LineNumber
+	originalType := getOriginalType(lookup, obfuscatedFrame.Type)
+	originalArguments := getOriginalArguments(lookup, obfuscatedFrame.Arguments)
+
+	// Find all matching methods
+	for _, methodInfo := range methodInfos {
+		if !methodInfo.Matches(obfuscatedLineNumber, originalType, originalArguments) {
+			continue
+		}
+
+		originalMethodFrames = append(originalMethodFrames, FrameInfo{
+			methodInfo.OriginalClassName,
+			getSourceFileName(methodInfo.OriginalClassName),
+			methodInfo.OriginalLineNumber(obfuscatedLineNumber),
+			methodInfo.OriginalType,
+			obfuscatedFrame.FieldName,
+			methodInfo.OriginalName,
+			methodInfo.OriginalArguments,
+		})
+	}
+
+	return originalMethodFrames
+}
+
+func getSourceFileName(className string) string {
+	if len(className) == 0 {
+		return className
+	}
+
+	index1 := strings.LastIndex(className, ".") + 1
+	index2 := IndexOf(className, "$", index1)
+
+	// Nested classes are in the source file of their top-level class.
+	if index2 > 0 {
+		return className[index1:index2] + ".java"
+	} else {
+		return className[index1:] + ".java"
+	}
+}
+
+func getOriginalType(lookup mappingLookup, obfuscatedType string) string {
+	index := strings.Index(obfuscatedType, "[")
+	if index >= 0 {
+		return lookup.GetOriginalClassName(obfuscatedType[0:index]) + obfuscatedType[index:]
+	} else {
+		return lookup.GetOriginalClassName(obfuscatedType)
+	}
+}
+
+func getOriginalArguments(lookup mappingLookup, obfuscatedArguments string) string {
+	tokens := strings.Split(obfuscatedArguments, ",")
+	if len(tokens) < 1 {
+		return ""
+	}
+
+	originalArguments := make([]string, len(tokens))
+
+	for index, token := range tokens {
+		originalArguments[index] = getOriginalType(lookup, strings.TrimSpace(token))
+	}
+
+	return strings.Join(originalArguments, ",")
+}
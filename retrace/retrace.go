@@ -14,6 +14,59 @@ type Retrace struct {
 	AllClassNames      bool
 	Verbose            bool
 	MappingFileReader  io.Reader
+	// Remapper The mappings to retrace with, if they have already been
+	// read or indexed. Otherwise they are read from MappingFileReader.
+	Remapper Remapper
+	// SyntheticFrames How to print the frames of synthesized code, like
+	// lambda classes, accessors and bridge methods.
+	SyntheticFrames SyntheticFrameMode
+
+	// KotlinNames Whether to print the names of the methods that Kotlin
+	// generates as Kotlin developers think of them, like
+	// "Foo.bar (suspend lambda)" for "Foo$bar$1.invokeSuspend".
+	KotlinNames bool
+
+	// SourceFiles The sources of the source file names of retraced frames,
+	// before the names that are derived from the class names.
+	SourceFiles SourceFileStrategy
+
+	// CoroutineFrames Whether to leave out the frames that resume suspend
+	// functions and dispatch coroutines, and the frames that the stack trace
+	// recovery of kotlinx.coroutines duplicated across coroutine boundaries.
+	CoroutineFrames bool
+
+	// The pattern of frame lines, if the options need it.
+	atPattern *FramePattern
+	// The filter of synthetic frames, if they aren't kept.
+	syntheticFilter *syntheticFrameFilter
+	// The filter of coroutine frames, if they are left out.
+	coroutineFilter *coroutineFrameFilter
+	// The indentation of the last frame line, for the notes on folded frames
+	// at the end of a stack trace.
+	frameIndentation string
+	// Whether to collect the original frames of the line that is being
+	// retraced, and the frames.
+	collectFrames bool
+	lineFrames    []RetracedFrame
+}
+
+// RetracedLine A line of a retraced stack trace, with its original frames.
+type RetracedLine struct {
+	// Line The retraced line, without its line break.
+	Line   string          `json:"line"`
+	Frames []RetracedFrame `json:"frames,omitempty"`
+}
+
+// RetracedFrame An original frame of a retraced line, with the JVM names of
+// its class and method.
+type RetracedFrame struct {
+	ClassName  string `json:"className"`
+	FieldName  string `json:"fieldName,omitempty"`
+	MethodName string `json:"methodName,omitempty"`
+	SourceFile string `json:"sourceFile,omitempty"`
+	LineNumber int    `json:"lineNumber,omitempty"`
+	// Kotlin The Kotlin name of the method, if it differs.
+	Kotlin *KotlinName `json:"kotlin,omitempty"`
 }
 
 // For example: "com.example.Foo.bar"
@@ -57,6 +110,23 @@ var REGULAR_EXPRESSION_RETURN_VALUE_NULL2 = `.*?\bjava\.lang\.NullPointerExcepti
 // For example: Cannot invoke "java.net.ServerSocket.close()" because "com.example.Foo.bar" is null
 var REGULAR_EXPRESSION_BECAUSE_IS_NULL = `.*?\bbecause \"%c\.%f\" is null`
 
+// For example: "java.lang.NoSuchMethodError: No virtual method d(ILjava/lang/String;)V in class La/b/c; or its super classes"
+var REGULAR_EXPRESSION_NO_SUCH_METHOD = `.*?\bjava\.lang\.NoSuchMethodError: No (?:virtual |static |direct |interface |super )?method %m%D in class L%C;.*`
+
+// For example: "java.lang.NoSuchFieldError: No instance field d of type [La/b; in class La/b/c; or its superclasses"
+var REGULAR_EXPRESSION_NO_SUCH_FIELD = `.*?\bjava\.lang\.NoSuchFieldError: No (?:instance |static )?field %f of type %T in class L%C;.*`
+
+// For example: "Rejecting invocation of La/b/c;->d(ILjava/lang/String;)V"
+var REGULAR_EXPRESSION_DALVIK_METHOD = `.*?\bL%C;->%m%D.*`
+
+// For example: "Failed to resolve La/b/c;->d:[La/b;"
+var REGULAR_EXPRESSION_DALVIK_FIELD = `.*?\bL%C;->%f:%T.*`
+
+// For example: "Location: a/b/c.d:(I)V @3: invokevirtual"
+// The class must start at a word, so the pattern doesn't match the end of a
+// dotted name, like "a.b.c(I)V".
+var REGULAR_EXPRESSION_JVM_METHOD = `^(?:.*?[\s'"(])?%C\.%m:%D.*`
+
 // The overall regular expression for a line in the stack trace.
 var REGULAR_EXPRESSION = "(?:" + REGULAR_EXPRESSION_AT + ")|" +
 	"(?:" + REGULAR_EXPRESSION_CAST1 + ")|" +
@@ -66,6 +136,11 @@ var REGULAR_EXPRESSION = "(?:" + REGULAR_EXPRESSION_AT + ")|" +
 	"(?:" + REGULAR_EXPRESSION_NULL_METHOD + ")|" +
 	"(?:" + REGULAR_EXPRESSION_RETURN_VALUE_NULL1 + ")|" +
 	"(?:" + REGULAR_EXPRESSION_BECAUSE_IS_NULL + ")|" +
+	"(?:" + REGULAR_EXPRESSION_NO_SUCH_METHOD + ")|" +
+	"(?:" + REGULAR_EXPRESSION_NO_SUCH_FIELD + ")|" +
+	"(?:" + REGULAR_EXPRESSION_DALVIK_METHOD + ")|" +
+	"(?:" + REGULAR_EXPRESSION_DALVIK_FIELD + ")|" +
+	"(?:" + REGULAR_EXPRESSION_JVM_METHOD + ")|" +
 	"(?:" + REGULAR_EXPRESSION_THROW + ")"
 
 // DIRTY FIX:
@@ -81,23 +156,85 @@ func NewRetrace(mappingFileReader io.Reader) *Retrace {
 	retrace.RegularExpression2 = REGULAR_EXPRESSION2
 	retrace.AllClassNames = false
 	retrace.Verbose = false
+	retrace.SourceFiles = DefaultSourceFileStrategy
 	retrace.MappingFileReader = mappingFileReader
 
 	return &retrace
 }
 
+// NewRetraceWithRemapper Creates a Retrace with mappings that have already
+// been read, for example from several mapping files with a MappingMerger, or
+// from a MappingIndex.
+func NewRetraceWithRemapper(remapper Remapper) *Retrace {
+	retrace := NewRetrace(nil)
+	retrace.Remapper = remapper
+
+	return retrace
+}
+
 func (r *Retrace) Retrace(reader io.Reader, writer io.Writer) {
 	bufWriter := bufio.NewWriter(writer)
 
+	r.process(reader, func(line string, frames []RetracedFrame) {
+		bufWriter.WriteString(line)
+	})
+
+	bufWriter.Flush()
+}
+
+// RetraceLines Retraces the given stack trace like Retrace, and returns the
+// retraced lines with their original frames, for structured output. The
+// lines of hidden frames are left out.
+func (r *Retrace) RetraceLines(reader io.Reader) []RetracedLine {
+	var lines []RetracedLine
+	r.collectFrames = true
+	r.process(reader, func(line string, frames []RetracedFrame) {
+		if len(line) == 0 {
+			return
+		}
+
+		lines = append(lines, RetracedLine{
+			Line:   strings.TrimRight(line, "\r\n"),
+			Frames: frames,
+		})
+	})
+	r.collectFrames = false
+
+	return lines
+}
+
+// process Retraces the lines of the given stack trace, and passes each
+// retraced line, with its original frames if they are collected, to the
+// given function.
+func (r *Retrace) process(reader io.Reader, processLine func(line string, frames []RetracedFrame)) {
 	// create a pattern for stack frames
 	pattern1 := NewFramePattern(r.RegularExpression, r.Verbose)
 	pattern2 := NewFramePattern(r.RegularExpression2, r.Verbose)
 
-	mapper := NewFrameRemapper()
+	mapper := r.Remapper
+	if mapper == nil {
+		frameRemapper := NewFrameRemapper()
 
-	// Read the mapping file
-	mappingReader := NewMappingReader(r.MappingFileReader)
-	mappingReader.Pump(mapper)
+		// Read the mapping file
+		mappingReader := NewMappingReader(r.MappingFileReader)
+		mappingReader.Pump(frameRemapper)
+		mapper = frameRemapper
+	}
+
+	r.atPattern = nil
+	if r.SyntheticFrames != SyntheticFramesKeep || r.KotlinNames || r.CoroutineFrames || r.collectFrames {
+		r.atPattern = NewFramePattern(REGULAR_EXPRESSION_AT, false)
+	}
+
+	r.syntheticFilter = nil
+	if r.SyntheticFrames != SyntheticFramesKeep {
+		r.syntheticFilter = newSyntheticFrameFilter(r.SyntheticFrames)
+	}
+
+	r.coroutineFilter = nil
+	if r.CoroutineFrames {
+		r.coroutineFilter = newCoroutineFrameFilter()
+	}
 
 	// Read and process the lines of the stack trace.
 	bufReader := bufio.NewReader(reader)
@@ -107,31 +244,132 @@ func (r *Retrace) Retrace(reader io.Reader, writer io.Writer) {
 			break
 		}
 
+		// Frames are only folded into frames of the same trace. The frames
+		// of a recovered coroutine stack trace continue after a boundary.
+		if r.coroutineFilter != nil && IsCoroutineBoundary(obfuscatedLine) {
+			r.coroutineFilter.boundary()
+		} else if r.atPattern != nil && !r.isFrameLine(obfuscatedLine) {
+			r.flushFoldedFrames(processLine)
+			if r.syntheticFilter != nil {
+				r.syntheticFilter.reset()
+			}
+			if r.coroutineFilter != nil {
+				r.coroutineFilter.reset()
+			}
+		}
+
 		obfuscatedFrame1 := pattern1.Parse(obfuscatedLine)
 		obfuscatedFrame2 := pattern2.Parse(obfuscatedLine)
 
+		r.lineFrames = nil
 		deobf := r.handle(&obfuscatedFrame1, mapper, pattern1, &obfuscatedLine)
 		// DIRTY FIX:
 		// I have to execute it two times because recent Java stacktraces may have multiple fields/methods in the same line.
 		// For example: java.lang.NullPointerException: Cannot invoke "com.example.Foo.bar.foo(int)" because the return value of "com.example.Foo.bar.foo2()" is null
 		deobf = r.handle(&obfuscatedFrame2, mapper, pattern2, &deobf)
 
-		bufWriter.WriteString(deobf)
+		processLine(deobf, r.lineFrames)
+
+		if r.isFrameLine(obfuscatedLine) {
+			r.frameIndentation = obfuscatedLine[:len(obfuscatedLine)-len(strings.TrimLeft(obfuscatedLine, " \t"))]
+		}
 	}
 
-	bufWriter.Flush()
+	r.flushFoldedFrames(processLine)
+}
+
+// flushFoldedFrames Passes a line with the numbers of the frames that have
+// been folded at the end of a stack trace, since no printed frame follows to
+// note them.
+func (r *Retrace) flushFoldedFrames(processLine func(line string, frames []RetracedFrame)) {
+	var notes []string
+	if r.syntheticFilter != nil {
+		if note := r.syntheticFilter.flush(); len(note) > 0 {
+			notes = append(notes, note)
+		}
+	}
+
+	if r.coroutineFilter != nil {
+		if note := r.coroutineFilter.flush(); len(note) > 0 {
+			notes = append(notes, note)
+		}
+	}
+
+	if len(notes) > 0 {
+		processLine(r.frameIndentation+"("+strings.Join(notes, ", ")+")\n", nil)
+	}
+}
+
+// isFrameLine returns whether the given line is a stack frame, rather than
+// a message that mentions a method.
+func (r *Retrace) isFrameLine(line string) bool {
+	return r.atPattern != nil && len(r.atPattern.Parse(line).MethodName) > 0
 }
 
-func (r *Retrace) handle(obfuscatedFrame *FrameInfo, mapper *FrameRemapper, pattern *FramePattern, obfuscatedLine *string) string {
+func (r *Retrace) handle(obfuscatedFrame *FrameInfo, mapper Remapper, pattern *FramePattern, obfuscatedLine *string) string {
 	result := bytes.NewBufferString("")
 	if obfuscatedFrame != nil {
 		// Transform the obfuscated frame back to one or more original frames.
 		retracedFrames := mapper.Transform(obfuscatedFrame)
 
+		// Only stack frames have source files. Without the pattern of stack
+		// frames, the source files are resolved for all lines, since only
+		// the stack frames print them.
+		frameLine := len(obfuscatedFrame.MethodName) > 0 && r.isFrameLine(*obfuscatedLine)
+		for index := range retracedFrames {
+			if frameLine || r.atPattern == nil {
+				retracedFrames[index].SourceFile = ResolveSourceFile(r.SourceFiles, mapper, obfuscatedFrame, &retracedFrames[index])
+			} else {
+				retracedFrames[index].SourceFile = ""
+			}
+		}
+		if r.coroutineFilter != nil && frameLine {
+			retracedFrames = r.coroutineFilter.filter(retracedFrames)
+		}
+		if r.syntheticFilter != nil && frameLine {
+			retracedFrames = r.syntheticFilter.filter(obfuscatedFrame, retracedFrames, mapper)
+		}
+
 		var previousLine *string = nil
 
 		for _, retracedFrame := range retracedFrames {
-			retracedLine := pattern.Format(*obfuscatedLine, retracedFrame)
+			var kotlinName *KotlinName
+			if r.KotlinNames && frameLine {
+				if name := DemangleKotlin(retracedFrame.ClassName, retracedFrame.MethodName); name.Demangled() {
+					kotlinName = &name
+				}
+			}
+
+			if r.collectFrames && len(obfuscatedFrame.ClassName) > 0 {
+				r.lineFrames = append(r.lineFrames, RetracedFrame{
+					ClassName:  retracedFrame.ClassName,
+					FieldName:  retracedFrame.FieldName,
+					MethodName: retracedFrame.MethodName,
+					SourceFile: retracedFrame.SourceFile,
+					LineNumber: retracedFrame.LineNumber,
+					Kotlin:     kotlinName,
+				})
+			}
+
+			var retracedLine string
+			if kotlinName != nil {
+				// Print the Kotlin name, with the kind of the method.
+				kotlinFrame := retracedFrame
+				kotlinFrame.ClassName = kotlinName.ClassName
+				kotlinFrame.MethodName = kotlinName.MethodName
+				retracedLine = pattern.Format(*obfuscatedLine, kotlinFrame)
+				if len(kotlinName.Kind) > 0 {
+					retracedLine = appendLineNote(retracedLine, kotlinName.Kind)
+				}
+			} else {
+				retracedLine = pattern.Format(*obfuscatedLine, retracedFrame)
+			}
+			if r.syntheticFilter != nil && frameLine {
+				retracedLine = r.syntheticFilter.annotate(retracedLine, &retracedFrame, kotlinName == nil)
+			}
+			if r.coroutineFilter != nil && frameLine {
+				retracedLine = r.coroutineFilter.annotate(retracedLine, &retracedFrame)
+			}
 
 			// Clear the common first part of ambiguous alternative
 			// retraced lines, to present a cleaner list of alternatives.
@@ -201,7 +439,7 @@ func deobfuscateFieldsFunc(c rune) bool {
 		c == '/' || c == '\\'
 }
 
-func (r *Retrace) Deobfuscate(line *string, mapper *FrameRemapper) string {
+func (r *Retrace) Deobfuscate(line *string, mapper Remapper) string {
 	var buff strings.Builder
 
 	// Try to deobfuscate any token encountered in the line.
@@ -0,0 +1,318 @@
+package retrace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// For example ".class public final La/b;".
+var smaliClassPattern = regexp.MustCompile(`^\s*\.class\s+(?:\S+\s+)*(\S+)\s*$`)
+
+// For example ".method public static a(ILa/b;)V".
+var smaliMethodPattern = regexp.MustCompile(`^(\s*\.method\s+(?:\S+\s+)*)([^\s(]+)(\([^()\s]*\)\S+)(.*)$`)
+
+// For example ".field private static final a:La/b; = null".
+var smaliFieldPattern = regexp.MustCompile(`^(\s*\.field\s+(?:[a-z-]+\s+)*)([^\s:]+):(\S+)(.*)$`)
+
+// For example ".line 12".
+var smaliLinePattern = regexp.MustCompile(`^(\s*\.line\s+)(\d+)(.*)$`)
+
+// A reference to a field or method, a method descriptor, or a type, in
+// smali code outside of string literals.
+const smaliTypeExpression = `\[*(?:L[^;\s()]+;|[ZBCSIJFDV])`
+
+var smaliReferencePattern = regexp.MustCompile(
+	`(\[*L[^;\s()]+;)->([^\s(:]+)(\([^()\s]*\)` + smaliTypeExpression + `|:` + smaliTypeExpression + `)` +
+		`|\([^()\s]*\)` + smaliTypeExpression +
+		`|\[*L[^;\s()]+;`)
+
+// A class in a fragment of a generic signature, like "Ljava/util/List<".
+var smaliSignatureClassPattern = regexp.MustCompile(`L([^;<>\s"]+)([;<])`)
+
+// The annotation that holds the generic signatures of classes and members as
+// fragments of strings.
+const smaliSignatureAnnotation = "Ldalvik/annotation/Signature;"
+
+// smaliMethod The method of which a SmaliDeobfuscator is deobfuscating the
+// code.
+type smaliMethod struct {
+	obfuscatedName string
+	info           MethodInfo
+	// Whether the method is in the mapping.
+	mapped bool
+}
+
+// SmaliDeobfuscator Deobfuscates smali files, as written by baksmali, with
+// the mappings of a FrameRemapper: the names of the class, its super class,
+// its interfaces, its fields and its methods, the classes, fields and methods
+// to which the code refers, and the line numbers of the code.
+type SmaliDeobfuscator struct {
+	remapper *FrameRemapper
+
+	// The obfuscated and original names of the class that is being
+	// deobfuscated.
+	obfuscatedClassName string
+	originalClassName   string
+	// The method that is being deobfuscated, if any.
+	method *smaliMethod
+	// Whether the annotation that is being deobfuscated is a generic
+	// signature.
+	inSignature bool
+}
+
+func NewSmaliDeobfuscator(remapper *FrameRemapper) *SmaliDeobfuscator {
+	deobfuscator := SmaliDeobfuscator{
+		remapper: remapper,
+	}
+
+	return &deobfuscator
+}
+
+// Deobfuscate Writes the deobfuscated smali code of the given smali file, and
+// returns the original name of its class.
+func (deobfuscator *SmaliDeobfuscator) Deobfuscate(reader io.Reader, writer io.Writer) (string, error) {
+	deobfuscator.obfuscatedClassName = ""
+	deobfuscator.originalClassName = ""
+	deobfuscator.method = nil
+	deobfuscator.inSignature = false
+
+	bufWriter := bufio.NewWriter(writer)
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, err := bufReader.ReadString('\n')
+		if len(line) > 0 {
+			bufWriter.WriteString(deobfuscator.deobfuscateLine(line))
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return deobfuscator.originalClassName, err
+		}
+	}
+
+	if len(deobfuscator.originalClassName) == 0 {
+		return "", fmt.Errorf("no .class directive")
+	}
+
+	return deobfuscator.originalClassName, bufWriter.Flush()
+}
+
+// ObfuscatedClassName returns the obfuscated name of the class of the smali
+// file that was deobfuscated last.
+func (deobfuscator *SmaliDeobfuscator) ObfuscatedClassName() string {
+	return deobfuscator.obfuscatedClassName
+}
+
+func (deobfuscator *SmaliDeobfuscator) deobfuscateLine(line string) string {
+	content := strings.TrimRight(line, "\r\n")
+	lineBreak := line[len(content):]
+	directive := strings.TrimSpace(content)
+
+	switch {
+	case strings.HasPrefix(directive, ".class "):
+		if match := smaliClassPattern.FindStringSubmatch(content); match != nil {
+			deobfuscator.obfuscatedClassName = ExternalType(match[1])
+			deobfuscator.originalClassName = deobfuscator.remapper.GetOriginalClassName(deobfuscator.obfuscatedClassName)
+		}
+	case strings.HasPrefix(directive, ".method "):
+		if match := smaliMethodPattern.FindStringSubmatch(content); match != nil {
+			deobfuscator.method = deobfuscator.definedMethod(match[2], match[3])
+			name := match[2]
+			if deobfuscator.method.mapped {
+				name = deobfuscator.method.info.OriginalName
+			}
+			return match[1] + name + deobfuscator.deobfuscateCode(match[3]+match[4]) + lineBreak
+		}
+	case strings.HasPrefix(directive, ".end method"):
+		deobfuscator.method = nil
+	case strings.HasPrefix(directive, ".field "):
+		if match := smaliFieldPattern.FindStringSubmatch(content); match != nil {
+			name := deobfuscator.fieldName(deobfuscator.originalClassName, match[2], match[3])
+			return match[1] + name + ":" + deobfuscator.deobfuscateCode(match[3]+match[4]) + lineBreak
+		}
+	case strings.HasPrefix(directive, ".line "):
+		if match := smaliLinePattern.FindStringSubmatch(content); match != nil {
+			return match[1] + deobfuscator.lineNumber(match[2]) + match[3] + lineBreak
+		}
+	case strings.HasPrefix(directive, ".annotation "):
+		deobfuscator.inSignature = strings.HasSuffix(directive, " "+smaliSignatureAnnotation)
+	case strings.HasPrefix(directive, ".end annotation"):
+		deobfuscator.inSignature = false
+	}
+
+	return deobfuscator.deobfuscateCode(content) + lineBreak
+}
+
+// deobfuscateCode Deobfuscates the references in the given smali code,
+// leaving string literals and comments as they are, except for the
+// fragments of generic signatures.
+func (deobfuscator *SmaliDeobfuscator) deobfuscateCode(code string) string {
+	var buffer strings.Builder
+	start := 0
+	inString := false
+	for index := 0; index < len(code); index++ {
+		switch code[index] {
+		case '\\':
+			if inString {
+				index++
+			}
+		case '"':
+			if inString {
+				// The string literal, with its quotes.
+				buffer.WriteString(deobfuscator.deobfuscateString(code[start : index+1]))
+				start = index + 1
+			} else {
+				buffer.WriteString(deobfuscator.deobfuscateReferences(code[start:index]))
+				start = index
+			}
+			inString = !inString
+		case '#':
+			if !inString {
+				buffer.WriteString(deobfuscator.deobfuscateReferences(code[start:index]))
+				buffer.WriteString(code[index:])
+				return buffer.String()
+			}
+		}
+	}
+
+	if inString {
+		buffer.WriteString(code[start:])
+	} else {
+		buffer.WriteString(deobfuscator.deobfuscateReferences(code[start:]))
+	}
+
+	return buffer.String()
+}
+
+// deobfuscateString Deobfuscates the given string literal, with its quotes,
+// if it is a fragment of a generic signature.
+func (deobfuscator *SmaliDeobfuscator) deobfuscateString(literal string) string {
+	if !deobfuscator.inSignature {
+		return literal
+	}
+
+	return smaliSignatureClassPattern.ReplaceAllStringFunc(literal, func(fragment string) string {
+		match := smaliSignatureClassPattern.FindStringSubmatch(fragment)
+		return "L" + InternalClassName(deobfuscator.remapper.GetOriginalClassName(ExternalClassName(match[1]))) + match[2]
+	})
+}
+
+// deobfuscateReferences Deobfuscates the references to fields and methods,
+// the method descriptors and the types in the given smali code.
+func (deobfuscator *SmaliDeobfuscator) deobfuscateReferences(code string) string {
+	return smaliReferencePattern.ReplaceAllStringFunc(code, func(reference string) string {
+		match := smaliReferencePattern.FindStringSubmatch(reference)
+		if len(match[1]) == 0 {
+			return deobfuscator.deobfuscateDescriptor(reference)
+		}
+
+		// A field or method of a class.
+		className := deobfuscator.deobfuscateDescriptor(match[1])
+		originalClassName := ExternalType(className)
+		name := match[2]
+		if strings.HasPrefix(match[3], ":") {
+			name = deobfuscator.fieldName(originalClassName, name, match[3][1:])
+		} else {
+			method := deobfuscator.referencedMethod(originalClassName, name, match[3])
+			if method.mapped {
+				name = method.info.OriginalName
+			}
+		}
+
+		return className + "->" + name + deobfuscator.deobfuscateDescriptor(match[3])
+	})
+}
+
+func (deobfuscator *SmaliDeobfuscator) deobfuscateDescriptor(descriptor string) string {
+	return RemapDescriptor(descriptor, deobfuscator.remapper.GetOriginalClassName)
+}
+
+// fieldName Returns the original name of the field with the given obfuscated
+// name and type descriptor in the given original class, or the obfuscated
+// name if it isn't in the mapping.
+func (deobfuscator *SmaliDeobfuscator) fieldName(originalClassName string, obfuscatedName string, descriptor string) string {
+	originalType := ExternalType(deobfuscator.deobfuscateDescriptor(descriptor))
+	for _, fieldInfo := range deobfuscator.remapper.fieldInfos(originalClassName, obfuscatedName) {
+		if fieldInfo.OriginalType == originalType {
+			return fieldInfo.OriginalName
+		}
+	}
+
+	return obfuscatedName
+}
+
+// referencedMethod Returns the method with the given obfuscated name and
+// method descriptor in the given original class.
+func (deobfuscator *SmaliDeobfuscator) referencedMethod(originalClassName string, obfuscatedName string, descriptor string) smaliMethod {
+	originalDescriptor := deobfuscator.deobfuscateDescriptor(descriptor)
+	originalType := ExternalMethodReturnType(originalDescriptor)
+	originalArguments := ExternalMethodArguments(originalDescriptor)
+
+	method := smaliMethod{obfuscatedName: obfuscatedName}
+	for _, methodInfo := range deobfuscator.remapper.methodInfos(originalClassName, obfuscatedName, 0) {
+		// Inlined methods of other classes aren't defined in this class.
+		if methodInfo.OriginalClassName == originalClassName &&
+			methodInfo.OriginalType == originalType &&
+			methodInfo.OriginalArguments == originalArguments {
+			method.info = methodInfo
+			method.mapped = true
+			break
+		}
+	}
+
+	return method
+}
+
+// definedMethod Returns the method with the given obfuscated name and method
+// descriptor in the class that is being deobfuscated.
+func (deobfuscator *SmaliDeobfuscator) definedMethod(obfuscatedName string, descriptor string) *smaliMethod {
+	method := deobfuscator.referencedMethod(deobfuscator.originalClassName, obfuscatedName, descriptor)
+	return &method
+}
+
+// lineNumber Returns the original line number of the given obfuscated line
+// number in the method that is being deobfuscated, with a comment on the
+// innermost inlined method if the code at the line was inlined.
+func (deobfuscator *SmaliDeobfuscator) lineNumber(obfuscatedLineNumber string) string {
+	method := deobfuscator.method
+	lineNumber, err := strconv.Atoi(obfuscatedLineNumber)
+	if method == nil || !method.mapped || err != nil {
+		return obfuscatedLineNumber
+	}
+
+	// The inlined methods precede the method into which they were inlined,
+	// with the same obfuscated line range.
+	var group []MethodInfo
+	for _, methodInfo := range deobfuscator.remapper.methodInfos(deobfuscator.originalClassName, method.obfuscatedName, lineNumber) {
+		if !methodInfo.Matches(lineNumber, "", "") {
+			group = nil
+			continue
+		}
+		if len(group) > 0 &&
+			(group[0].ObfuscatedFirstLineNumber != methodInfo.ObfuscatedFirstLineNumber ||
+				group[0].ObfuscatedLastLineNumber != methodInfo.ObfuscatedLastLineNumber) {
+			group = nil
+		}
+		group = append(group, methodInfo)
+
+		if methodInfo.OriginalClassName == method.info.OriginalClassName &&
+			methodInfo.OriginalName == method.info.OriginalName &&
+			methodInfo.OriginalType == method.info.OriginalType &&
+			methodInfo.OriginalArguments == method.info.OriginalArguments {
+			originalLineNumber := strconv.Itoa(methodInfo.OriginalLineNumber(lineNumber))
+			if len(group) == 1 {
+				return originalLineNumber
+			}
+
+			inlined := group[0]
+			return fmt.Sprintf("%s # inlined from %s.%s:%d", originalLineNumber,
+				inlined.OriginalClassName, inlined.OriginalName, inlined.OriginalLineNumber(lineNumber))
+		}
+	}
+
+	return obfuscatedLineNumber
+}
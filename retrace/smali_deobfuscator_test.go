@@ -0,0 +1,132 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const smaliMapping = `com.example.Foo -> a.b:
+    com.example.Bar[] items -> a
+    java.lang.String name -> b
+    1:1:void show(int,com.example.Bar):10:10 -> a
+    2:2:boolean com.example.Util.check(com.example.Bar):30:30 -> a
+    2:2:void show(int,com.example.Bar):11 -> a
+    3:3:java.util.List items():20:20 -> a
+com.example.Bar -> a.c:
+    int count -> a
+    void reset() -> b
+com.example.Listener -> a.d:
+`
+
+const smaliCode = `.class public final La/b;
+.super La/c;
+.source "SourceFile"
+
+# interfaces
+.implements La/d;
+
+.annotation system Ldalvik/annotation/Signature;
+    value = {
+        "La/c;",
+        "La/d<",
+        "La/c;",
+        ">;"
+    }
+.end annotation
+
+# instance fields
+.field private a:[La/c;
+
+.field private b:Ljava/lang/String; = "La/c;"
+
+.method public a(ILa/c;)V
+    .registers 4
+
+    .line 1
+    iget-object v0, p0, La/b;->a:[La/c;
+
+    .line 2
+    invoke-virtual {p2}, La/c;->b()V
+
+    iget v1, p2, La/c;->a:I
+
+    const-string v0, "La/c;->b()V"
+
+    check-cast p2, La/c; # La/c;
+
+    return-void
+.end method
+
+.method public final a()Ljava/util/List;
+    .registers 2
+
+    .line 3
+    const/4 v0, 0x0
+
+    return-object v0
+.end method
+`
+
+func TestSmaliDeobfuscator(t *testing.T) {
+	remapper := NewFrameRemapper()
+	NewMappingReader(strings.NewReader(smaliMapping)).Pump(remapper)
+
+	result := bytes.NewBufferString("")
+	className, err := NewSmaliDeobfuscator(remapper).Deobfuscate(strings.NewReader(smaliCode), result)
+	assert.NoError(t, err)
+	assert.Equal(t, "com.example.Foo", className)
+	assert.Equal(t, `.class public final Lcom/example/Foo;
+.super Lcom/example/Bar;
+.source "SourceFile"
+
+# interfaces
+.implements Lcom/example/Listener;
+
+.annotation system Ldalvik/annotation/Signature;
+    value = {
+        "Lcom/example/Bar;",
+        "Lcom/example/Listener<",
+        "Lcom/example/Bar;",
+        ">;"
+    }
+.end annotation
+
+# instance fields
+.field private items:[Lcom/example/Bar;
+
+.field private name:Ljava/lang/String; = "La/c;"
+
+.method public show(ILcom/example/Bar;)V
+    .registers 4
+
+    .line 10
+    iget-object v0, p0, Lcom/example/Foo;->items:[Lcom/example/Bar;
+
+    .line 11 # inlined from com.example.Util.check:30
+    invoke-virtual {p2}, Lcom/example/Bar;->reset()V
+
+    iget v1, p2, Lcom/example/Bar;->count:I
+
+    const-string v0, "La/c;->b()V"
+
+    check-cast p2, Lcom/example/Bar; # La/c;
+
+    return-void
+.end method
+
+.method public final items()Ljava/util/List;
+    .registers 2
+
+    .line 20
+    const/4 v0, 0x0
+
+    return-object v0
+.end method
+`, result.String())
+
+	_, err = NewSmaliDeobfuscator(remapper).Deobfuscate(strings.NewReader("# not smali\n"), bytes.NewBufferString(""))
+	assert.Error(t, err)
+}
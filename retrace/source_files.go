@@ -0,0 +1,161 @@
+package retrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The id of R8's metadata for the source file of a class.
+const sourceFileMetadataID = "sourceFile"
+
+// SourceFileSource A source of the source file names of retraced frames.
+type SourceFileSource int
+
+const (
+	// SourceFileMetadata The source file in R8's metadata of the class, like
+	// "# {"id":"sourceFile","fileName":"Foo.kt"}".
+	SourceFileMetadata SourceFileSource = iota
+	// SourceFileFrame The source file of the obfuscated frame, if the build
+	// kept it and the original frame is in the same top-level class.
+	SourceFileFrame
+	// SourceFileKotlin The source file of a Kotlin file facade, like
+	// "Utils.kt" for "UtilsKt".
+	SourceFileKotlin
+)
+
+// SourceFileStrategy The sources of the source file names of retraced
+// frames, in order of precedence. The source file names that are derived from
+// the class names, like "Foo.java" for "Foo$1", are the last resort.
+type SourceFileStrategy []SourceFileSource
+
+// DefaultSourceFileStrategy Uses the metadata first, then the source file of
+// the obfuscated frame, and then the names of Kotlin file facades.
+var DefaultSourceFileStrategy = SourceFileStrategy{
+	SourceFileMetadata,
+	SourceFileFrame,
+	SourceFileKotlin,
+}
+
+// SourceFileSourceNames The names of the sources of source file names, for
+// command line options.
+var SourceFileSourceNames = map[string]SourceFileSource{
+	"metadata": SourceFileMetadata,
+	"frame":    SourceFileFrame,
+	"kotlin":   SourceFileKotlin,
+}
+
+// ParseSourceFileStrategy returns the strategy with the given comma-separated
+// names of sources, like "metadata,frame,kotlin". An empty string or "java"
+// only derives the source files from the class names.
+func ParseSourceFileStrategy(names string) (SourceFileStrategy, error) {
+	strategy := SourceFileStrategy{}
+	if len(names) == 0 || names == "java" {
+		return strategy, nil
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		source, ok := SourceFileSourceNames[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown source file source %q, expected metadata, frame or kotlin", name)
+		}
+		strategy = append(strategy, source)
+	}
+
+	return strategy, nil
+}
+
+// SourceFileLookup This interface can optionally be implemented by a
+// Remapper that knows the source files of classes from R8's metadata in the
+// mapping.
+type SourceFileLookup interface {
+	// SourceFileName Returns the source file of the given original class in
+	// the metadata, or an empty string.
+	SourceFileName(originalClassName string) string
+}
+
+// sourceFileFromComments returns the file name of R8's source file metadata
+// in the given mapping comments, or an empty string.
+func sourceFileFromComments(comments []string) string {
+	for _, comment := range comments {
+		comment = strings.TrimSpace(comment)
+		if !strings.HasPrefix(comment, "{") || !strings.Contains(comment, sourceFileMetadataID) {
+			continue
+		}
+
+		var metadata struct {
+			ID       string `json:"id"`
+			FileName string `json:"fileName"`
+		}
+
+		if json.Unmarshal([]byte(comment), &metadata) == nil &&
+			metadata.ID == sourceFileMetadataID &&
+			isRealSourceFile(metadata.FileName) {
+			return metadata.FileName
+		}
+	}
+
+	return ""
+}
+
+// isRealSourceFile returns whether the given source file of a frame or of
+// metadata names a file, rather than being a placeholder.
+func isRealSourceFile(sourceFile string) bool {
+	switch sourceFile {
+	case "", "SourceFile", "Unknown Source", "Native Method":
+		return false
+	default:
+		return true
+	}
+}
+
+// topLevelClassName returns the name of the top-level class of the given
+// class, like "com.example.Foo" for "com.example.Foo$Bar$1".
+func topLevelClassName(className string) string {
+	index1 := strings.LastIndex(className, ".") + 1
+	if index2 := IndexOf(className, "$", index1); index2 > 0 {
+		return className[:index2]
+	}
+
+	return className
+}
+
+// ResolveSourceFile Returns the source file of the given original frame of
+// the given obfuscated frame, with the given strategy, or the source file
+// that the original frame already has.
+func ResolveSourceFile(strategy SourceFileStrategy, mapper Remapper, obfuscatedFrame *FrameInfo, originalFrame *FrameInfo) string {
+	if len(originalFrame.ClassName) == 0 {
+		return originalFrame.SourceFile
+	}
+
+	for _, source := range strategy {
+		switch source {
+		case SourceFileMetadata:
+			if metadata, ok := mapper.(SourceFileLookup); ok {
+				// Nested classes are in the source file of their top-level
+				// class.
+				if sourceFile := metadata.SourceFileName(originalFrame.ClassName); len(sourceFile) > 0 {
+					return sourceFile
+				}
+				if sourceFile := metadata.SourceFileName(topLevelClassName(originalFrame.ClassName)); len(sourceFile) > 0 {
+					return sourceFile
+				}
+			}
+		case SourceFileFrame:
+			// The source file of the obfuscated frame belongs to its class,
+			// not to the classes of any inlined methods.
+			if isRealSourceFile(obfuscatedFrame.SourceFile) &&
+				topLevelClassName(mapper.GetOriginalClassName(obfuscatedFrame.ClassName)) == topLevelClassName(originalFrame.ClassName) {
+				return obfuscatedFrame.SourceFile
+			}
+		case SourceFileKotlin:
+			className := topLevelClassName(originalFrame.ClassName)
+			simpleName := className[strings.LastIndex(className, ".")+1:]
+			if len(simpleName) > 2 && strings.HasSuffix(simpleName, "Kt") {
+				return strings.TrimSuffix(simpleName, "Kt") + ".kt"
+			}
+		}
+	}
+
+	return originalFrame.SourceFile
+}
@@ -0,0 +1,77 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sourceFilesMapping = `com.example.Foo -> a:
+# {"id":"sourceFile","fileName":"Screens.kt"}
+    1:1:void show():10:10 -> a
+com.example.Foo$show$1 -> b:
+    1:1:java.lang.Object invokeSuspend(java.lang.Object):20:20 -> a
+com.example.StringsKt -> c:
+    1:1:java.lang.String trim(java.lang.String):30:30 -> a
+com.example.Bar -> d:
+    1:1:void run():40:40 -> a
+com.example.Bar$1 -> e:
+    1:1:void run():50:50 -> a
+`
+
+const sourceFilesTrace = `java.lang.IllegalStateException: boom
+	at a.a(SourceFile:1)
+	at b.a(SourceFile:1)
+	at c.a(SourceFile:1)
+	at d.a(Bar.java:1)
+	at e.a(SourceFile:1)
+`
+
+func retraceSourceFiles(strategy SourceFileStrategy) string {
+	retrace := NewRetrace(strings.NewReader(sourceFilesMapping))
+	retrace.SourceFiles = strategy
+
+	result := bytes.NewBufferString("")
+	retrace.Retrace(strings.NewReader(sourceFilesTrace), result)
+	return result.String()
+}
+
+func TestSourceFiles(t *testing.T) {
+	assert.Equal(t, `java.lang.IllegalStateException: boom
+	at com.example.Foo.show(Screens.kt:10)
+	at com.example.Foo$show$1.invokeSuspend(Screens.kt:20)
+	at com.example.StringsKt.trim(Strings.kt:30)
+	at com.example.Bar.run(Bar.java:40)
+	at com.example.Bar$1.run(Bar.java:50)
+`, retraceSourceFiles(DefaultSourceFileStrategy))
+
+	assert.Equal(t, `java.lang.IllegalStateException: boom
+	at com.example.Foo.show(Foo.java:10)
+	at com.example.Foo$show$1.invokeSuspend(Foo.java:20)
+	at com.example.StringsKt.trim(StringsKt.java:30)
+	at com.example.Bar.run(Bar.java:40)
+	at com.example.Bar$1.run(Bar.java:50)
+`, retraceSourceFiles(SourceFileStrategy{}))
+}
+
+func TestResolveSourceFileFromFrame(t *testing.T) {
+	remapper := NewFrameRemapper()
+	NewMappingReader(strings.NewReader(sourceFilesMapping)).Pump(remapper)
+
+	obfuscatedFrame := FrameInfo{ClassName: "d", SourceFile: "Worker.kt", MethodName: "a", LineNumber: 1}
+	originalFrame := FrameInfo{ClassName: "com.example.Bar", SourceFile: "Bar.java", MethodName: "run"}
+	assert.Equal(t, "Worker.kt", ResolveSourceFile(DefaultSourceFileStrategy, remapper, &obfuscatedFrame, &originalFrame))
+
+	// The source file of the obfuscated frame doesn't apply to inlined
+	// methods of other classes.
+	inlinedFrame := FrameInfo{ClassName: "com.example.Other", SourceFile: "Other.java", MethodName: "run"}
+	assert.Equal(t, "Other.java", ResolveSourceFile(DefaultSourceFileStrategy, remapper, &obfuscatedFrame, &inlinedFrame))
+
+	strategy, err := ParseSourceFileStrategy("frame,kotlin")
+	assert.NoError(t, err)
+	assert.Equal(t, SourceFileStrategy{SourceFileFrame, SourceFileKotlin}, strategy)
+	_, err = ParseSourceFileStrategy("guess")
+	assert.Error(t, err)
+}
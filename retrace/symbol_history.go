@@ -0,0 +1,72 @@
+package retrace
+
+// SymbolRelease The symbols that a lookup found in the mapping of one
+// release.
+type SymbolRelease struct {
+	Release string `json:"release"`
+	// Track The releases that follow each other, like the releases of an
+	// app and flavor, if there are several.
+	Track   string   `json:"track,omitempty"`
+	Symbols []Symbol `json:"symbols"`
+}
+
+// SymbolHistoryRange Consecutive releases in which a lookup found the same
+// symbols, or none.
+type SymbolHistoryRange struct {
+	FirstRelease string   `json:"firstRelease"`
+	LastRelease  string   `json:"lastRelease"`
+	Track        string   `json:"track,omitempty"`
+	Releases     int      `json:"releases"`
+	Symbols      []Symbol `json:"symbols"`
+}
+
+// SummarizeSymbolHistory Groups the given releases, oldest first within each
+// track, into the ranges of consecutive releases in which a name meant the
+// same, so that a name that kept its meaning for many releases is reported
+// once. The tracks are summarized separately, in the order in which they
+// first appear.
+func SummarizeSymbolHistory(releases []SymbolRelease) []SymbolHistoryRange {
+	var tracks []string
+	trackRanges := make(map[string][]SymbolHistoryRange)
+	for _, release := range releases {
+		ranges, ok := trackRanges[release.Track]
+		if !ok {
+			tracks = append(tracks, release.Track)
+		}
+
+		if count := len(ranges); count > 0 && sameSymbols(ranges[count-1].Symbols, release.Symbols) {
+			ranges[count-1].LastRelease = release.Release
+			ranges[count-1].Releases++
+		} else {
+			ranges = append(ranges, SymbolHistoryRange{
+				FirstRelease: release.Release,
+				LastRelease:  release.Release,
+				Track:        release.Track,
+				Releases:     1,
+				Symbols:      release.Symbols,
+			})
+		}
+		trackRanges[release.Track] = ranges
+	}
+
+	var ranges []SymbolHistoryRange
+	for _, track := range tracks {
+		ranges = append(ranges, trackRanges[track]...)
+	}
+
+	return ranges
+}
+
+func sameSymbols(symbols1 []Symbol, symbols2 []Symbol) bool {
+	if len(symbols1) != len(symbols2) {
+		return false
+	}
+
+	for index := range symbols1 {
+		if symbols1[index] != symbols2[index] {
+			return false
+		}
+	}
+
+	return true
+}
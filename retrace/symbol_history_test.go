@@ -0,0 +1,68 @@
+package retrace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeSymbolHistory(t *testing.T) {
+	mappings := []string{
+		"com.example.Foo -> a:\n    void bar() -> b\n",
+		"com.example.Foo -> a:\n    void bar() -> b\n    int count -> c\n",
+		"com.example.Foo -> a:\n    void baz() -> b\n",
+		"com.example.Foo -> b:\n    void bar() -> a\n",
+	}
+
+	var forward []SymbolRelease
+	var reverse []SymbolRelease
+	for index, mapping := range mappings {
+		remapper := NewFrameRemapper()
+		assert.NoError(t, NewMappingReader(strings.NewReader(mapping)).Pump(remapper))
+		inverse := NewInverseFrameRemapper()
+		assert.NoError(t, NewMappingReader(strings.NewReader(mapping)).Pump(inverse))
+
+		release := string(rune('1' + index))
+		forward = append(forward, SymbolRelease{Release: release, Symbols: LookupObfuscatedSymbol(remapper, "a.b")})
+		reverse = append(reverse, SymbolRelease{Release: release, Symbols: LookupOriginalSymbol(inverse, "com.example.Foo.bar")})
+	}
+
+	ranges := SummarizeSymbolHistory(forward)
+	assert.Len(t, ranges, 3)
+	assert.Equal(t, "1", ranges[0].FirstRelease)
+	assert.Equal(t, "2", ranges[0].LastRelease)
+	assert.Equal(t, 2, ranges[0].Releases)
+	assert.Equal(t, "bar", ranges[0].Symbols[0].Name)
+	assert.Equal(t, "baz", ranges[1].Symbols[0].Name)
+	assert.Empty(t, ranges[2].Symbols)
+
+	ranges = SummarizeSymbolHistory(reverse)
+	assert.Len(t, ranges, 3)
+	assert.Equal(t, 2, ranges[0].Releases)
+	assert.Equal(t, "a", ranges[0].Symbols[0].ObfuscatedClassName)
+	assert.Empty(t, ranges[1].Symbols)
+	assert.Equal(t, "b", ranges[2].Symbols[0].ObfuscatedClassName)
+	assert.Equal(t, "a", ranges[2].Symbols[0].ObfuscatedName)
+}
+
+func TestSummarizeSymbolHistoryTracks(t *testing.T) {
+	bar := []Symbol{{Name: "bar"}}
+	baz := []Symbol{{Name: "baz"}}
+
+	// The releases of two flavors that mean the same in turns must not be
+	// merged across the flavors.
+	ranges := SummarizeSymbolHistory([]SymbolRelease{
+		{Release: "1 free", Track: "free", Symbols: bar},
+		{Release: "1 paid", Track: "paid", Symbols: baz},
+		{Release: "2 free", Track: "free", Symbols: bar},
+		{Release: "2 paid", Track: "paid", Symbols: bar},
+		{Release: "3 free", Track: "free", Symbols: baz},
+	})
+
+	assert.Len(t, ranges, 4)
+	assert.Equal(t, SymbolHistoryRange{FirstRelease: "1 free", LastRelease: "2 free", Track: "free", Releases: 2, Symbols: bar}, ranges[0])
+	assert.Equal(t, SymbolHistoryRange{FirstRelease: "3 free", LastRelease: "3 free", Track: "free", Releases: 1, Symbols: baz}, ranges[1])
+	assert.Equal(t, SymbolHistoryRange{FirstRelease: "1 paid", LastRelease: "1 paid", Track: "paid", Releases: 1, Symbols: baz}, ranges[2])
+	assert.Equal(t, SymbolHistoryRange{FirstRelease: "2 paid", LastRelease: "2 paid", Track: "paid", Releases: 1, Symbols: bar}, ranges[3])
+}
@@ -0,0 +1,239 @@
+package retrace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SymbolKind The kind of a symbol: a class, a field or a method.
+type SymbolKind string
+
+const (
+	SymbolClass  SymbolKind = "class"
+	SymbolField  SymbolKind = "field"
+	SymbolMethod SymbolKind = "method"
+)
+
+// Symbol A class or class member mapping that matches a lookup.
+type Symbol struct {
+	Kind                SymbolKind `json:"kind"`
+	ClassName           string     `json:"className"`
+	ObfuscatedClassName string     `json:"obfuscatedClassName"`
+	Type                string     `json:"type,omitempty"`
+	Name                string     `json:"name,omitempty"`
+	ObfuscatedName      string     `json:"obfuscatedName,omitempty"`
+	Arguments           string     `json:"arguments,omitempty"`
+
+	// The original and obfuscated line ranges of a method, or 0 if they are
+	// not known.
+	FirstLineNumber           int `json:"firstLineNumber,omitempty"`
+	LastLineNumber            int `json:"lastLineNumber,omitempty"`
+	ObfuscatedFirstLineNumber int `json:"obfuscatedFirstLineNumber,omitempty"`
+	ObfuscatedLastLineNumber  int `json:"obfuscatedLastLineNumber,omitempty"`
+
+	// LineNumber The original line number of the obfuscated line number
+	// that was looked up, if any.
+	LineNumber int `json:"lineNumber,omitempty"`
+	// ObfuscatedLineNumber The first obfuscated line number of the original
+	// line number that was looked up, if any.
+	ObfuscatedLineNumber int `json:"obfuscatedLineNumber,omitempty"`
+
+	// Inlined Whether the method was inlined into another method in its
+	// obfuscated line range, rather than being the method itself.
+	Inlined bool `json:"inlined,omitempty"`
+}
+
+// String returns a readable description of the symbol, like
+// "com.example.Foo: void bar(int):10:12 -> a.b:1:3".
+func (symbol Symbol) String() string {
+	if symbol.Kind == SymbolClass {
+		return symbol.ClassName + " -> " + symbol.ObfuscatedClassName
+	}
+
+	var buffer strings.Builder
+	fmt.Fprintf(&buffer, "%s: %s %s", symbol.ClassName, symbol.Type, symbol.Name)
+	if symbol.Kind == SymbolMethod {
+		fmt.Fprintf(&buffer, "(%s)", symbol.Arguments)
+		if symbol.LastLineNumber != 0 {
+			fmt.Fprintf(&buffer, ":%d:%d", symbol.FirstLineNumber, symbol.LastLineNumber)
+		}
+	}
+
+	fmt.Fprintf(&buffer, " -> %s.%s", symbol.ObfuscatedClassName, symbol.ObfuscatedName)
+	if symbol.ObfuscatedLastLineNumber != 0 {
+		fmt.Fprintf(&buffer, ":%d:%d", symbol.ObfuscatedFirstLineNumber, symbol.ObfuscatedLastLineNumber)
+	}
+
+	if symbol.LineNumber != 0 {
+		fmt.Fprintf(&buffer, " (line %d)", symbol.LineNumber)
+	}
+	if symbol.ObfuscatedLineNumber != 0 {
+		fmt.Fprintf(&buffer, " (obfuscated line %d)", symbol.ObfuscatedLineNumber)
+	}
+	if symbol.Inlined {
+		buffer.WriteString(" (inlined)")
+	}
+
+	return buffer.String()
+}
+
+// ParseSymbolQuery Splits a lookup like "a.b.c:12", "com.example.Foo" or
+// "com.example.Foo.bar(int):12" into its name, its optional method
+// arguments and its optional line number, which is 0 if it is absent.
+func ParseSymbolQuery(query string) (name string, arguments string, lineNumber int) {
+	name = strings.TrimSpace(query)
+
+	if colonIndex := strings.LastIndex(name, ":"); colonIndex >= 0 {
+		if number, err := strconv.Atoi(strings.TrimSpace(name[colonIndex+1:])); err == nil {
+			lineNumber = number
+			name = strings.TrimSpace(name[:colonIndex])
+		}
+	}
+
+	if argumentIndex1 := strings.Index(name, "("); argumentIndex1 >= 0 {
+		argumentIndex2 := IndexOf(name, ")", argumentIndex1+1)
+		if argumentIndex2 < 0 {
+			argumentIndex2 = len(name)
+		}
+		arguments = strings.ReplaceAll(name[argumentIndex1+1:argumentIndex2], " ", "")
+		name = name[:argumentIndex1]
+	}
+
+	return name, arguments, lineNumber
+}
+
+// LookupObfuscatedSymbol returns the original candidates of an obfuscated
+// class, "class.member" or "class.member:line", in the order in which
+// Retrace would print them.
+func LookupObfuscatedSymbol(remapper *FrameRemapper, query string) []Symbol {
+	name, _, lineNumber := ParseSymbolQuery(query)
+
+	var symbols []Symbol
+	if className, ok := remapper.ClassMap[name]; ok {
+		symbols = append(symbols, Symbol{
+			Kind:                SymbolClass,
+			ClassName:           className,
+			ObfuscatedClassName: name,
+		})
+	}
+
+	dotIndex := strings.LastIndex(name, ".")
+	if dotIndex < 0 {
+		return symbols
+	}
+
+	obfuscatedClassName := name[:dotIndex]
+	obfuscatedMemberName := name[dotIndex+1:]
+	className := remapper.GetOriginalClassName(obfuscatedClassName)
+
+	if fieldSet, ok := remapper.ClassFieldMap[className][obfuscatedMemberName]; ok {
+		for _, fieldInfo := range fieldSet.Values() {
+			symbols = append(symbols, Symbol{
+				Kind:                SymbolField,
+				ClassName:           fieldInfo.OriginalClassName,
+				ObfuscatedClassName: obfuscatedClassName,
+				Type:                fieldInfo.OriginalType,
+				Name:                fieldInfo.OriginalName,
+				ObfuscatedName:      obfuscatedMemberName,
+			})
+		}
+	}
+
+	if methodSet, ok := remapper.ClassMethodMap[className][obfuscatedMemberName]; ok {
+		var methodSymbols []Symbol
+		for _, methodInfo := range methodSet.ValuesAt(lineNumber) {
+			symbol := Symbol{
+				Kind:                      SymbolMethod,
+				ClassName:                 methodInfo.OriginalClassName,
+				ObfuscatedClassName:       obfuscatedClassName,
+				Type:                      methodInfo.OriginalType,
+				Name:                      methodInfo.OriginalName,
+				ObfuscatedName:            obfuscatedMemberName,
+				Arguments:                 methodInfo.OriginalArguments,
+				FirstLineNumber:           methodInfo.OriginalFirstLineNumber,
+				LastLineNumber:            methodInfo.OriginalLastLineNumber,
+				ObfuscatedFirstLineNumber: methodInfo.ObfuscatedFirstLineNumber,
+				ObfuscatedLastLineNumber:  methodInfo.ObfuscatedLastLineNumber,
+			}
+			if lineNumber != 0 {
+				symbol.LineNumber = methodInfo.OriginalLineNumber(lineNumber)
+			}
+
+			// The inlined methods precede the method that they were
+			// inlined into, in the same obfuscated line range.
+			if count := len(methodSymbols); count > 0 {
+				previous := &methodSymbols[count-1]
+				previous.Inlined = previous.ObfuscatedLastLineNumber != 0 &&
+					previous.ObfuscatedFirstLineNumber == symbol.ObfuscatedFirstLineNumber &&
+					previous.ObfuscatedLastLineNumber == symbol.ObfuscatedLastLineNumber
+			}
+
+			methodSymbols = append(methodSymbols, symbol)
+		}
+		symbols = append(symbols, methodSymbols...)
+	}
+
+	return symbols
+}
+
+// LookupOriginalSymbol returns the obfuscated candidates of an original
+// class, "class.member", "class.method(arguments)" or "class.member:line",
+// including the copies of methods that were inlined elsewhere.
+func LookupOriginalSymbol(inverse *InverseFrameRemapper, query string) []Symbol {
+	name, arguments, lineNumber := ParseSymbolQuery(query)
+
+	var symbols []Symbol
+	if obfuscatedClassName, ok := inverse.ClassMap[name]; ok {
+		symbols = append(symbols, Symbol{
+			Kind:                SymbolClass,
+			ClassName:           name,
+			ObfuscatedClassName: obfuscatedClassName,
+		})
+	}
+
+	dotIndex := strings.LastIndex(name, ".")
+	if dotIndex < 0 {
+		return symbols
+	}
+
+	className := name[:dotIndex]
+	memberName := name[dotIndex+1:]
+
+	if len(arguments) == 0 {
+		for _, fieldInfo := range inverse.FindFields(className, memberName) {
+			symbols = append(symbols, Symbol{
+				Kind:                SymbolField,
+				ClassName:           fieldInfo.OriginalClassName,
+				ObfuscatedClassName: fieldInfo.ObfuscatedClassName,
+				Type:                fieldInfo.OriginalType,
+				Name:                fieldInfo.OriginalName,
+				ObfuscatedName:      fieldInfo.ObfuscatedName,
+			})
+		}
+	}
+
+	for _, methodInfo := range inverse.FindMethods(className, memberName, arguments, lineNumber) {
+		symbol := Symbol{
+			Kind:                      SymbolMethod,
+			ClassName:                 methodInfo.OriginalClassName,
+			ObfuscatedClassName:       methodInfo.ObfuscatedClassName,
+			Type:                      methodInfo.OriginalType,
+			Name:                      methodInfo.OriginalName,
+			ObfuscatedName:            methodInfo.ObfuscatedName,
+			Arguments:                 methodInfo.OriginalArguments,
+			FirstLineNumber:           methodInfo.OriginalFirstLineNumber,
+			LastLineNumber:            methodInfo.OriginalLastLineNumber,
+			ObfuscatedFirstLineNumber: methodInfo.ObfuscatedFirstLineNumber,
+			ObfuscatedLastLineNumber:  methodInfo.ObfuscatedLastLineNumber,
+			Inlined:                   methodInfo.Inlined,
+		}
+		if lineNumber != 0 {
+			symbol.ObfuscatedLineNumber, _ = methodInfo.ObfuscatedLineNumbers(lineNumber)
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
@@ -0,0 +1,82 @@
+package retrace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const symbolMappingData = `com.example.Foo -> a:
+    int count -> a
+    1:3:void run(int):10:12 -> a
+    4:4:void com.example.Bar.helper():30:30 -> b
+    4:4:void run(int):13 -> b
+com.example.Bar -> b:
+    1:1:void helper():30:30 -> a
+`
+
+func TestParseSymbolQuery(t *testing.T) {
+	name, arguments, lineNumber := ParseSymbolQuery(" a.b.c:12 ")
+	assert.Equal(t, "a.b.c", name)
+	assert.Equal(t, "", arguments)
+	assert.Equal(t, 12, lineNumber)
+
+	name, arguments, lineNumber = ParseSymbolQuery("com.example.Foo.bar(int, java.lang.String):7")
+	assert.Equal(t, "com.example.Foo.bar", name)
+	assert.Equal(t, "int,java.lang.String", arguments)
+	assert.Equal(t, 7, lineNumber)
+
+	name, _, lineNumber = ParseSymbolQuery("com.example.Foo")
+	assert.Equal(t, "com.example.Foo", name)
+	assert.Equal(t, 0, lineNumber)
+}
+
+func TestLookupObfuscatedSymbol(t *testing.T) {
+	remapper := NewFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(symbolMappingData)).Pump(remapper))
+
+	assert.Equal(t, []Symbol{
+		{Kind: SymbolClass, ClassName: "com.example.Foo", ObfuscatedClassName: "a"},
+	}, LookupObfuscatedSymbol(remapper, "a"))
+
+	symbols := LookupObfuscatedSymbol(remapper, "a.a")
+	assert.Equal(t, 2, len(symbols))
+	assert.Equal(t, "com.example.Foo: int count -> a.a", symbols[0].String())
+	assert.Equal(t, "com.example.Foo: void run(int):10:12 -> a.a:1:3", symbols[1].String())
+
+	symbols = LookupObfuscatedSymbol(remapper, "a.a:2")
+	assert.Equal(t, 11, symbols[1].LineNumber)
+
+	symbols = LookupObfuscatedSymbol(remapper, "a.b:4")
+	assert.Equal(t, 2, len(symbols))
+	assert.Equal(t, "com.example.Bar: void helper():30:30 -> a.b:4:4 (line 30) (inlined)", symbols[0].String())
+	assert.Equal(t, "com.example.Foo: void run(int):13:13 -> a.b:4:4 (line 13)", symbols[1].String())
+
+	assert.Empty(t, LookupObfuscatedSymbol(remapper, "a.b:9"))
+	assert.Empty(t, LookupObfuscatedSymbol(remapper, "z"))
+}
+
+func TestLookupOriginalSymbol(t *testing.T) {
+	inverse := NewInverseFrameRemapper()
+	assert.NoError(t, NewMappingReader(strings.NewReader(symbolMappingData)).Pump(inverse))
+
+	symbols := LookupOriginalSymbol(inverse, "com.example.Bar.helper()")
+	assert.Equal(t, 2, len(symbols))
+	assert.Equal(t, "com.example.Bar: void helper():30:30 -> a.b:4:4 (inlined)", symbols[0].String())
+	assert.Equal(t, "com.example.Bar: void helper():30:30 -> b.a:1:1", symbols[1].String())
+
+	symbols = LookupOriginalSymbol(inverse, "com.example.Foo.run:11")
+	assert.Equal(t, 1, len(symbols))
+	assert.Equal(t, 2, symbols[0].ObfuscatedLineNumber)
+
+	symbols = LookupOriginalSymbol(inverse, "com.example.Foo.count")
+	assert.Equal(t, []Symbol{{
+		Kind:                SymbolField,
+		ClassName:           "com.example.Foo",
+		ObfuscatedClassName: "a",
+		Type:                "int",
+		Name:                "count",
+		ObfuscatedName:      "a",
+	}}, symbols)
+}
@@ -0,0 +1,249 @@
+package retrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The id of R8's metadata for synthesized classes and class members.
+const synthesizedMetadataID = "com.android.tools.r8.synthesized"
+
+// SyntheticFrameMode How Retrace prints the frames of code that the compilers
+// synthesized, like lambda classes, accessors and bridge methods.
+type SyntheticFrameMode int
+
+const (
+	// SyntheticFramesKeep Prints synthetic frames like any other frames.
+	SyntheticFramesKeep SyntheticFrameMode = iota
+	// SyntheticFramesHide Leaves out synthetic frames.
+	SyntheticFramesHide
+	// SyntheticFramesFold Leaves out synthetic frames, and notes their number
+	// at the next frame that is printed.
+	SyntheticFramesFold
+)
+
+// SyntheticFrameModeNames The names of the synthetic frame modes, for
+// command line options.
+var SyntheticFrameModeNames = map[string]SyntheticFrameMode{
+	"keep": SyntheticFramesKeep,
+	"hide": SyntheticFramesHide,
+	"fold": SyntheticFramesFold,
+}
+
+// ParseSyntheticFrameMode returns the synthetic frame mode with the given
+// name: "keep", "hide" or "fold".
+func ParseSyntheticFrameMode(name string) (SyntheticFrameMode, error) {
+	mode, ok := SyntheticFrameModeNames[name]
+	if !ok {
+		return SyntheticFramesKeep, fmt.Errorf("unknown synthetic frame mode %q, expected keep, hide or fold", name)
+	}
+
+	return mode, nil
+}
+
+// SyntheticMetadata This interface can optionally be implemented by a
+// Remapper that knows which classes and class members R8 marked as
+// synthesized in the mapping.
+type SyntheticMetadata interface {
+	// IsSynthesized Returns whether the given original class, or the class
+	// member with the given obfuscated name in it, was synthesized. The
+	// member name may be empty to only check the class.
+	IsSynthesized(originalClassName string, obfuscatedMemberName string) bool
+}
+
+// isSynthesizedComment returns whether the given mapping comment is R8's
+// metadata like "{"id":"com.android.tools.r8.synthesized"}".
+func isSynthesizedComment(comment string) bool {
+	comment = strings.TrimSpace(comment)
+	if !strings.HasPrefix(comment, "{") || !strings.Contains(comment, synthesizedMetadataID) {
+		return false
+	}
+
+	var metadata struct {
+		ID string `json:"id"`
+	}
+
+	return json.Unmarshal([]byte(comment), &metadata) == nil && metadata.ID == synthesizedMetadataID
+}
+
+func containsSynthesizedComment(comments []string) bool {
+	for _, comment := range comments {
+		if isSynthesizedComment(comment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The parts of class names of synthesized classes, like lambda classes of
+// javac, D8 and R8, and R8's backports.
+var syntheticClassNameParts = []string{
+	"-$$Lambda$",
+	"$$Lambda$",
+	"$$ExternalSynthetic",
+	"$$SyntheticClass",
+	"$r8$",
+}
+
+// The prefixes of synthesized method names, like javac's accessors and D8's
+// nest accessors.
+var syntheticMethodNamePrefixes = []string{
+	"access$",
+	"-$$Nest$",
+	"$r8$",
+}
+
+// IsSyntheticFrame Returns whether the given original frame is in code that
+// the compilers synthesized, judging by its class name and method name.
+func IsSyntheticFrame(frame *FrameInfo) bool {
+	for _, part := range syntheticClassNameParts {
+		if strings.Contains(frame.ClassName, part) {
+			return true
+		}
+	}
+
+	for _, prefix := range syntheticMethodNamePrefixes {
+		if strings.HasPrefix(frame.MethodName, prefix) {
+			return true
+		}
+	}
+
+	// Kotlin's methods that fill in default arguments.
+	return strings.HasSuffix(frame.MethodName, "$default")
+}
+
+// The method names of lambda bodies: "lambda$onCreate$0" of javac,
+// "onCreate$lambda$0" and "onCreate$lambda-0" of Kotlin.
+var lambdaMethodNamePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^lambda\$(.+)\$\d+$`),
+	regexp.MustCompile(`^(.+)\$lambda[$-]\d+$`),
+}
+
+// LambdaLabel Returns a label like "lambda in Foo.onCreate" for the given
+// original frame if it is in the body of a lambda, or an empty string.
+func LambdaLabel(frame *FrameInfo) string {
+	for _, pattern := range lambdaMethodNamePatterns {
+		if match := pattern.FindStringSubmatch(frame.MethodName); match != nil {
+			className := frame.ClassName[strings.LastIndex(frame.ClassName, ".")+1:]
+			return "lambda in " + className + "." + match[1]
+		}
+	}
+
+	return ""
+}
+
+// syntheticFrameFilter Leaves out the synthetic frames of the consecutive
+// frame lines that Retrace prints.
+type syntheticFrameFilter struct {
+	mode SyntheticFrameMode
+
+	// The last original frame, which is called by the next frame, and the
+	// obfuscated frame from which it was retraced.
+	previousFrame           FrameInfo
+	previousObfuscatedFrame FrameInfo
+	// The number of synthetic frames that have been left out since the last
+	// printed frame.
+	foldedFrames int
+}
+
+func newSyntheticFrameFilter(mode SyntheticFrameMode) *syntheticFrameFilter {
+	filter := syntheticFrameFilter{
+		mode: mode,
+	}
+
+	return &filter
+}
+
+// reset Forgets the previous frames, at the end of a stack trace.
+func (filter *syntheticFrameFilter) reset() {
+	filter.previousFrame = FrameInfo{}
+	filter.previousObfuscatedFrame = FrameInfo{}
+	filter.foldedFrames = 0
+}
+
+// filter Returns the given original frames of the given obfuscated frame
+// without the synthetic ones.
+func (filter *syntheticFrameFilter) filter(obfuscatedFrame *FrameInfo, originalFrames []FrameInfo, mapper Remapper) []FrameInfo {
+	metadata, _ := mapper.(SyntheticMetadata)
+
+	var keptFrames []FrameInfo
+	for index := range originalFrames {
+		frame := &originalFrames[index]
+
+		synthetic := IsSyntheticFrame(frame) ||
+			// A bridge method calls the method with the same name, and
+			// doesn't have a line number of its own. It is a different
+			// method in the obfuscated code, unlike a recursive call.
+			(frame.LineNumber <= 0 &&
+				frame.ClassName == filter.previousFrame.ClassName &&
+				frame.MethodName == filter.previousFrame.MethodName &&
+				(obfuscatedFrame.ClassName != filter.previousObfuscatedFrame.ClassName ||
+					obfuscatedFrame.MethodName != filter.previousObfuscatedFrame.MethodName))
+
+		if !synthetic && metadata != nil {
+			// The member metadata belongs to the obfuscated method, which
+			// may contain inlined methods, so it is only reliable for a
+			// single frame.
+			synthetic = metadata.IsSynthesized(frame.ClassName, "") ||
+				(len(originalFrames) == 1 &&
+					metadata.IsSynthesized(mapper.GetOriginalClassName(obfuscatedFrame.ClassName), obfuscatedFrame.MethodName))
+		}
+
+		filter.previousFrame = *frame
+		if synthetic {
+			filter.foldedFrames++
+		} else {
+			keptFrames = append(keptFrames, *frame)
+		}
+	}
+
+	filter.previousObfuscatedFrame = *obfuscatedFrame
+
+	return keptFrames
+}
+
+// annotate Returns the given retraced line of the given original frame with
+// the label of its lambda, if requested, and with the number of synthetic
+// frames that were folded into it.
+func (filter *syntheticFrameFilter) annotate(line string, frame *FrameInfo, labelLambda bool) string {
+	var notes []string
+	if label := LambdaLabel(frame); labelLambda && len(label) > 0 {
+		notes = append(notes, label)
+	}
+
+	if note := filter.flush(); len(note) > 0 {
+		notes = append(notes, note)
+	}
+
+	return appendLineNote(line, notes...)
+}
+
+// flush Returns a note with the number of synthetic frames that have been
+// folded since the last printed frame, if they are folded, and forgets them.
+func (filter *syntheticFrameFilter) flush() string {
+	folded := filter.foldedFrames
+	filter.foldedFrames = 0
+
+	switch {
+	case filter.mode != SyntheticFramesFold || folded == 0:
+		return ""
+	case folded == 1:
+		return "1 synthetic frame folded"
+	default:
+		return fmt.Sprintf("%d synthetic frames folded", folded)
+	}
+}
+
+// appendLineNote Returns the given line with the given notes in parentheses
+// at its end, before its line break.
+func appendLineNote(line string, notes ...string) string {
+	if len(notes) == 0 {
+		return line
+	}
+
+	content := strings.TrimRight(line, "\r\n")
+	return content + " (" + strings.Join(notes, ", ") + ")" + line[len(content):]
+}
@@ -0,0 +1,121 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const syntheticMapping = `com.example.Foo -> a:
+    1:1:void lambda$onCreate$0(android.view.View):42:42 -> a
+    2:2:void access$000(com.example.Foo):10:10 -> b
+    3:3:void onClick():50:50 -> c
+    4:4:void outline():0:0 -> d
+    # {"id":"com.android.tools.r8.synthesized"}
+com.example.Foo$$ExternalSyntheticLambda0 -> b:
+# {"id":"com.android.tools.r8.synthesized"}
+    void onClick(android.view.View) -> onClick
+com.example.Bar -> c:
+    5:5:void run():60:60 -> a
+`
+
+const syntheticTrace = `java.lang.IllegalStateException: boom
+	at a.a(SourceFile:1)
+	at b.onClick(Unknown Source:2)
+	at a.d(SourceFile:4)
+	at a.b(SourceFile:2)
+	at c.a(SourceFile:5)
+`
+
+func retraceSynthetic(t *testing.T, mode SyntheticFrameMode) string {
+	retrace := NewRetrace(strings.NewReader(syntheticMapping))
+	retrace.SyntheticFrames = mode
+
+	result := bytes.NewBufferString("")
+	retrace.Retrace(strings.NewReader(syntheticTrace), result)
+	return result.String()
+}
+
+func TestSyntheticFramesHide(t *testing.T) {
+	assert.Equal(t, `java.lang.IllegalStateException: boom
+	at com.example.Foo.lambda$onCreate$0(Foo.java:42) (lambda in Foo.onCreate)
+	at com.example.Bar.run(Bar.java:60)
+`, retraceSynthetic(t, SyntheticFramesHide))
+}
+
+func TestSyntheticFramesFold(t *testing.T) {
+	assert.Equal(t, `java.lang.IllegalStateException: boom
+	at com.example.Foo.lambda$onCreate$0(Foo.java:42) (lambda in Foo.onCreate)
+	at com.example.Bar.run(Bar.java:60) (3 synthetic frames folded)
+`, retraceSynthetic(t, SyntheticFramesFold))
+}
+
+func TestSyntheticFramesHideLines(t *testing.T) {
+	retrace := NewRetrace(strings.NewReader(syntheticMapping))
+	retrace.SyntheticFrames = SyntheticFramesHide
+
+	// The hidden frames leave no lines, and the exception has no source
+	// file.
+	lines := retrace.RetraceLines(strings.NewReader(syntheticTrace))
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, "java.lang.IllegalStateException: boom", lines[0].Line)
+	assert.Equal(t, "", lines[0].Frames[0].SourceFile)
+	assert.Equal(t, "\tat com.example.Foo.lambda$onCreate$0(Foo.java:42) (lambda in Foo.onCreate)", lines[1].Line)
+	assert.Equal(t, "Foo.java", lines[1].Frames[0].SourceFile)
+	assert.Equal(t, "\tat com.example.Bar.run(Bar.java:60)", lines[2].Line)
+	assert.Equal(t, "com.example.Bar", lines[2].Frames[0].ClassName)
+}
+
+func TestSyntheticFramesKeep(t *testing.T) {
+	assert.Equal(t, 6, strings.Count(retraceSynthetic(t, SyntheticFramesKeep), "\n"))
+}
+
+func TestIsSyntheticFrame(t *testing.T) {
+	assert.True(t, IsSyntheticFrame(&FrameInfo{ClassName: "com.example.Foo-$$Lambda$Bar$1", MethodName: "run"}))
+	assert.True(t, IsSyntheticFrame(&FrameInfo{ClassName: "com.example.Foo", MethodName: "access$100"}))
+	assert.True(t, IsSyntheticFrame(&FrameInfo{ClassName: "com.example.Foo", MethodName: "bar$default"}))
+	assert.False(t, IsSyntheticFrame(&FrameInfo{ClassName: "com.example.Foo", MethodName: "bar"}))
+
+	assert.Equal(t, "lambda in Foo.onCreate", LambdaLabel(&FrameInfo{ClassName: "com.example.Foo", MethodName: "onCreate$lambda$1"}))
+	assert.Equal(t, "lambda in Foo$Inner.run", LambdaLabel(&FrameInfo{ClassName: "com.example.Foo$Inner", MethodName: "run$lambda-0"}))
+	assert.Equal(t, "", LambdaLabel(&FrameInfo{ClassName: "com.example.Foo", MethodName: "lambda"}))
+
+	_, err := ParseSyntheticFrameMode("fold")
+	assert.NoError(t, err)
+	_, err = ParseSyntheticFrameMode("squash")
+	assert.Error(t, err)
+}
+
+const recursionMapping = `com.example.Tree -> t:
+    void walk() -> a
+    java.lang.Object compareTo(java.lang.Object) -> b
+    int compareTo(com.example.Tree) -> c
+`
+
+func TestSyntheticFramesRecursion(t *testing.T) {
+	retrace := NewRetrace(strings.NewReader(recursionMapping))
+	retrace.SyntheticFrames = SyntheticFramesFold
+
+	result := bytes.NewBufferString("")
+	retrace.Retrace(strings.NewReader(`java.lang.StackOverflowError
+	at t.a(Unknown Source)
+	at t.a(Unknown Source)
+	at t.a(Unknown Source)
+java.lang.IllegalStateException: boom
+	at t.c(Unknown Source)
+	at t.b(Unknown Source)
+`), result)
+
+	// Recursive calls aren't bridges, but a bridge at the end of the trace
+	// is still noted.
+	assert.Equal(t, `java.lang.StackOverflowError
+	at com.example.Tree.walk(Tree.java)
+	at com.example.Tree.walk(Tree.java)
+	at com.example.Tree.walk(Tree.java)
+java.lang.IllegalStateException: boom
+	at com.example.Tree.compareTo(Tree.java)
+	(1 synthetic frame folded)
+`, result.String())
+}
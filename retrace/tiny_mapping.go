@@ -0,0 +1,455 @@
+package retrace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Reference: https://fabricmc.net/wiki/documentation:tiny2
+
+const (
+	tinyObfuscatedNamespace = "official"
+	tinyOriginalNamespace   = "named"
+)
+
+// tinyMember A field or method of a Tiny v2 mapping, with its descriptor in
+// the first namespace.
+type tinyMember struct {
+	isMethod   bool
+	descriptor string
+	names      []string
+}
+
+// tinyClass A class of a Tiny v2 mapping, with its internal names.
+type tinyClass struct {
+	names   []string
+	members []tinyMember
+}
+
+// TinyMappingReader This MappingPump reads mappings in the Tiny v2 format of
+// Fabric, and passes them to a MappingProcessor like MappingReader does for
+// the ProGuard format.
+//
+// Tiny v2 mappings have names in several namespaces. The obfuscated names
+// are taken from the first namespace and the original names from the last
+// namespace, unless other namespaces are set. Tiny v2 mappings don't have
+// line numbers, but they can have parameter names, local variable names and
+// comments, which the MappingProcessor can't receive, so they are reported
+// as losses.
+type TinyMappingReader struct {
+	fileReader io.Reader
+
+	// ObfuscatedNamespace The namespace with the obfuscated names.
+	ObfuscatedNamespace string
+	// OriginalNamespace The namespace with the original names.
+	OriginalNamespace string
+
+	losses lossCounter
+}
+
+func NewTinyMappingReader(fileReader io.Reader) *TinyMappingReader {
+	reader := TinyMappingReader{
+		fileReader: fileReader,
+		losses:     make(lossCounter),
+	}
+
+	return &reader
+}
+
+func (r *TinyMappingReader) Losses() []string {
+	return r.losses.Losses()
+}
+
+func (r *TinyMappingReader) Pump(processor MappingProcessor) error {
+	scanner := bufio.NewScanner(r.fileReader)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return errors.New("empty Tiny mapping")
+	}
+
+	// "tiny	2	0	___	___..."
+	header := strings.Split(scanner.Text(), "\t")
+	if len(header) < 5 || header[0] != "tiny" || header[1] != "2" {
+		return errors.New("not a Tiny v2 mapping")
+	}
+	namespaces := header[3:]
+
+	obfuscatedIndex, err := tinyNamespaceIndex(namespaces, r.ObfuscatedNamespace, 0)
+	if err != nil {
+		return err
+	}
+	originalIndex, err := tinyNamespaceIndex(namespaces, r.OriginalNamespace, len(namespaces)-1)
+	if err != nil {
+		return err
+	}
+
+	// Read all classes first, since the descriptors of the members refer
+	// to classes by their names in the first namespace.
+	var (
+		classes      []*tinyClass
+		class        *tinyClass
+		escapedNames = false
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		depth := len(line) - len(strings.TrimLeft(line, "\t"))
+		columns := strings.Split(line[depth:], "\t")
+		if len(line) == depth {
+			continue
+		}
+
+		if escapedNames {
+			for index := range columns {
+				columns[index] = unescapeTinyName(columns[index])
+			}
+		}
+
+		switch {
+		case depth == 0 && columns[0] == "c":
+			// "c	___	___..."
+			class = &tinyClass{names: tinyNames(columns[1:], len(namespaces))}
+			classes = append(classes, class)
+		case depth == 1 && class == nil:
+			// A property of the header.
+			if columns[0] == "escaped-names" {
+				escapedNames = true
+			}
+		case depth == 1 && (columns[0] == "f" || columns[0] == "m") && len(columns) >= 3:
+			// "	f	___	___	___..." or "	m	___	___	___..."
+			class.members = append(class.members, tinyMember{
+				isMethod:   columns[0] == "m",
+				descriptor: columns[1],
+				names:      tinyNames(columns[2:], len(namespaces)),
+			})
+		case columns[0] == "c":
+			r.losses.add("comments")
+		case depth == 2 && columns[0] == "p":
+			r.losses.add("parameter names")
+		case depth == 2 && columns[0] == "v":
+			r.losses.add("local variable names")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// The names in the namespaces other than the two that are used.
+	for index, namespace := range namespaces {
+		if index != obfuscatedIndex && index != originalIndex {
+			for _, class := range classes {
+				r.losses.add("names in namespace " + namespace)
+				for range class.members {
+					r.losses.add("names in namespace " + namespace)
+				}
+			}
+		}
+	}
+
+	// First namespace class name -> original class name.
+	classNames := make(map[string]string)
+	for _, class := range classes {
+		classNames[ExternalClassName(class.names[0])] = ExternalClassName(class.names[originalIndex])
+	}
+
+	originalType := func(externalType string) string {
+		index := strings.Index(externalType, "[")
+		if index < 0 {
+			index = len(externalType)
+		}
+		if className, ok := classNames[externalType[:index]]; ok {
+			return className + externalType[index:]
+		}
+		return externalType
+	}
+
+	for _, class := range classes {
+		className := ExternalClassName(class.names[originalIndex])
+		if !processor.ProcessClassMapping(className, ExternalClassName(class.names[obfuscatedIndex])) {
+			continue
+		}
+
+		for _, member := range class.members {
+			if !member.isMethod {
+				processor.ProcessFieldMapping(
+					className,
+					originalType(ExternalType(member.descriptor)),
+					member.names[originalIndex],
+					className,
+					member.names[obfuscatedIndex],
+				)
+				continue
+			}
+
+			arguments, returnType := externalMethodType(member.descriptor)
+			for index := range arguments {
+				arguments[index] = originalType(arguments[index])
+			}
+
+			processor.ProcessMethodMapping(
+				className,
+				0,
+				0,
+				originalType(returnType),
+				member.names[originalIndex],
+				strings.Join(arguments, ","),
+				className,
+				0,
+				0,
+				member.names[obfuscatedIndex],
+			)
+		}
+	}
+
+	return nil
+}
+
+// tinyNamespaceIndex Returns the index of the given namespace, or the default
+// index if no namespace is given.
+func tinyNamespaceIndex(namespaces []string, namespace string, defaultIndex int) (int, error) {
+	if len(namespace) == 0 {
+		return defaultIndex, nil
+	}
+
+	for index, name := range namespaces {
+		if name == namespace {
+			return index, nil
+		}
+	}
+
+	return -1, fmt.Errorf("unknown namespace %s", namespace)
+}
+
+// tinyNames Returns the names of all namespaces, where missing names are the
+// same as the names in the first namespace.
+func tinyNames(columns []string, namespaceCount int) []string {
+	names := make([]string, namespaceCount)
+	copy(names, columns)
+	for index := range names {
+		if len(names[index]) == 0 {
+			names[index] = names[0]
+		}
+	}
+
+	return names
+}
+
+func unescapeTinyName(name string) string {
+	if !strings.Contains(name, "\\") {
+		return name
+	}
+
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\n`, "\n",
+		`\r`, "\r",
+		`\t`, "\t",
+		`\0`, "\x00",
+	).Replace(name)
+}
+
+// tinyWriterClass A class that TinyMappingWriter received, with its
+// members in terms of external types.
+type tinyWriterClass struct {
+	className    string
+	newClassName string
+	members      []tinyWriterMember
+}
+
+type tinyWriterMember struct {
+	isMethod   bool
+	memberType string
+	name       string
+	arguments  string
+	newName    string
+}
+
+// TinyMappingWriter This MappingProcessor writes the mappings it receives in
+// the Tiny v2 format of Fabric, with the obfuscated names in the "official"
+// namespace and the original names in the "named" namespace.
+//
+// Tiny v2 mappings don't have line numbers, inlined methods or metadata, so
+// they are left out and reported as losses. The mappings are written when
+// the writer is flushed, since the descriptors of the class members need the
+// obfuscated names of all classes.
+type TinyMappingWriter struct {
+	writer *bufio.Writer
+
+	// Original class name -> obfuscated class name.
+	classNames map[string]string
+	classes    []*tinyWriterClass
+	// The members that are already added to the current class.
+	members map[tinyWriterMember]bool
+
+	// The method that was received last, which is left out if the next
+	// method turns out to inline it.
+	pendingMethod           *tinyWriterMember
+	pendingMethodOtherClass bool
+	pendingRange            [2]int
+
+	losses lossCounter
+}
+
+func NewTinyMappingWriter(writer io.Writer) *TinyMappingWriter {
+	tinyWriter := TinyMappingWriter{
+		writer:     bufio.NewWriter(writer),
+		classNames: make(map[string]string),
+		losses:     make(lossCounter),
+	}
+
+	return &tinyWriter
+}
+
+func (w *TinyMappingWriter) Losses() []string {
+	return w.losses.Losses()
+}
+
+func (w *TinyMappingWriter) ProcessClassMapping(className string, newClassName string) bool {
+	w.flushPendingMethod()
+
+	w.classNames[className] = newClassName
+	w.classes = append(w.classes, &tinyWriterClass{className: className, newClassName: newClassName})
+	w.members = make(map[tinyWriterMember]bool)
+	return true
+}
+
+func (w *TinyMappingWriter) ProcessFieldMapping(
+	className string,
+	fieldType string,
+	fieldName string,
+	newClassName string,
+	newFieldName string) {
+
+	w.flushPendingMethod()
+
+	if className != newClassName {
+		w.losses.add("fields of other classes")
+		return
+	}
+
+	w.addMember(tinyWriterMember{
+		isMethod:   false,
+		memberType: fieldType,
+		name:       fieldName,
+		newName:    newFieldName,
+	})
+}
+
+func (w *TinyMappingWriter) ProcessMethodMapping(
+	className string,
+	firstLineNumber int,
+	lastLineNumber int,
+	methodType string,
+	methodName string,
+	arguments string,
+	newClassName string,
+	newFirstLineNumber int,
+	newLastLineNumber int,
+	newMethodName string) {
+
+	if newFirstLineNumber != 0 || newLastLineNumber != 0 {
+		w.losses.add("line number ranges")
+	}
+
+	// A method in the same line range as the previous method inlines it.
+	lineRange := [2]int{newFirstLineNumber, newLastLineNumber}
+	if w.pendingMethod != nil &&
+		newLastLineNumber != 0 &&
+		w.pendingMethod.newName == newMethodName &&
+		w.pendingRange == lineRange {
+		w.losses.add("inlined methods")
+		w.pendingMethod = nil
+	}
+	w.flushPendingMethod()
+
+	w.pendingMethod = &tinyWriterMember{
+		isMethod:   true,
+		memberType: methodType,
+		name:       methodName,
+		arguments:  arguments,
+		newName:    newMethodName,
+	}
+	w.pendingMethodOtherClass = className != newClassName
+	w.pendingRange = lineRange
+}
+
+func (w *TinyMappingWriter) ProcessComment(comment string) {
+	w.losses.add("comments")
+}
+
+// flushPendingMethod Adds the method that was received last, now that it's
+// clear that it isn't inlined.
+func (w *TinyMappingWriter) flushPendingMethod() {
+	if w.pendingMethod == nil {
+		return
+	}
+
+	if w.pendingMethodOtherClass {
+		// The outermost method of an inline group is inlined from
+		// another class, so the method itself is unknown.
+		w.losses.add("methods of other classes")
+	} else {
+		w.addMember(*w.pendingMethod)
+	}
+	w.pendingMethod = nil
+}
+
+func (w *TinyMappingWriter) addMember(member tinyWriterMember) {
+	if len(w.classes) == 0 || w.members[member] {
+		return
+	}
+	w.members[member] = true
+
+	class := w.classes[len(w.classes)-1]
+	class.members = append(class.members, member)
+}
+
+// obfuscatedType Returns the given original external type with the
+// obfuscated class name.
+func (w *TinyMappingWriter) obfuscatedType(externalType string) string {
+	index := strings.Index(externalType, "[")
+	if index < 0 {
+		index = len(externalType)
+	}
+	if className, ok := w.classNames[externalType[:index]]; ok {
+		return className + externalType[index:]
+	}
+	return externalType
+}
+
+func (w *TinyMappingWriter) Flush() error {
+	w.flushPendingMethod()
+
+	w.writer.WriteString("tiny\t2\t0\t" + tinyObfuscatedNamespace + "\t" + tinyOriginalNamespace + "\n")
+	for _, class := range w.classes {
+		// "c	___	___"
+		w.writer.WriteString("c\t" + InternalClassName(class.newClassName) + "\t" + InternalClassName(class.className) + "\n")
+
+		for _, member := range class.members {
+			if member.isMethod {
+				// "	m	___	___	___"
+				var obfuscatedArguments []string
+				if len(member.arguments) > 0 {
+					for _, argument := range strings.Split(member.arguments, ",") {
+						obfuscatedArguments = append(obfuscatedArguments, w.obfuscatedType(strings.TrimSpace(argument)))
+					}
+				}
+				w.writer.WriteString("\tm\t" + InternalMethodDescriptor(w.obfuscatedType(member.memberType), strings.Join(obfuscatedArguments, ",")))
+			} else {
+				// "	f	___	___	___"
+				w.writer.WriteString("\tf\t" + InternalType(w.obfuscatedType(member.memberType)))
+			}
+
+			w.writer.WriteString("\t" + member.newName + "\t" + member.name + "\n")
+		}
+	}
+
+	w.classes = nil
+	return w.writer.Flush()
+}
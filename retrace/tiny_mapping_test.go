@@ -0,0 +1,77 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tinyMappingData = "tiny\t2\t0\tofficial\tintermediary\tnamed\n" +
+	"c\ta\tnet/minecraft/class_1\tnet/minecraft/Foo\n" +
+	"\tc\tA class comment.\n" +
+	"\tf\tI\ta\tfield_1\tcount\n" +
+	"\tm\t(Lb;[I)La;\tb\tmethod_1\tcombine\n" +
+	"\t\tp\t1\t\t\tother\n" +
+	"c\tb\tnet/minecraft/class_2\tnet/minecraft/Bar\n"
+
+func TestTinyMappingReader(t *testing.T) {
+	output := bytes.NewBufferString("")
+	losses, err := ConvertMapping("tiny2", "proguard", strings.NewReader(tinyMappingData), output)
+	assert.NoError(t, err)
+
+	assert.Equal(t, `net.minecraft.Foo -> a:
+    int count -> a
+    net.minecraft.Foo combine(net.minecraft.Bar,int[]) -> b
+net.minecraft.Bar -> b:
+`, output.String())
+	assert.Equal(t, MappingLosses{
+		Read: []string{
+			"comments (1)",
+			"names in namespace intermediary (4)",
+			"parameter names (1)",
+		},
+	}, losses)
+}
+
+func TestTinyMappingWriter(t *testing.T) {
+	output := bytes.NewBufferString("")
+	losses, err := ConvertMapping("proguard", "tiny2", strings.NewReader(`# compiler: R8
+com.example.Foo -> a:
+    com.example.Bar bar -> a
+    1:3:void run(com.example.Bar[],int):10:12 -> b
+    4:4:void com.example.Bar.helper():30:30 -> b
+    4:4:void run(com.example.Bar[],int):13 -> b
+    5:5:void com.example.Bar.helper():31:31 -> c
+com.example.Bar -> b:
+    void helper() -> a
+`), output)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "tiny\t2\t0\tofficial\tnamed\n"+
+		"c\ta\tcom/example/Foo\n"+
+		"\tf\tLb;\ta\tbar\n"+
+		"\tm\t([Lb;I)V\tb\trun\n"+
+		"c\tb\tcom/example/Bar\n"+
+		"\tm\t()V\ta\thelper\n", output.String())
+	assert.Equal(t, MappingLosses{
+		Written: []string{
+			"comments (1)",
+			"inlined methods (1)",
+			"line number ranges (4)",
+			"methods of other classes (1)",
+		},
+	}, losses)
+
+	// Converting back gives the same names.
+	roundTrip := bytes.NewBufferString("")
+	_, err = ConvertMapping("tiny2", "proguard", output, roundTrip)
+	assert.NoError(t, err)
+	assert.Equal(t, `com.example.Foo -> a:
+    com.example.Bar bar -> a
+    void run(com.example.Bar[],int) -> b
+com.example.Bar -> b:
+    void helper() -> a
+`, roundTrip.String())
+}
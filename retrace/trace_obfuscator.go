@@ -0,0 +1,269 @@
+package retrace
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// inlineGroup The method mappings that share an obfuscated method and
+// obfuscated line range, with the innermost inlined method first and the
+// method into which they were inlined last.
+type inlineGroup struct {
+	obfuscatedClassName string
+	obfuscatedName      string
+	methods             []MethodInfo
+}
+
+// TraceObfuscator This MappingProcessor collects the mappings that it needs
+// to obfuscate readable stack traces, the reverse of Retrace, producing the
+// traces that the release build would have printed. Consecutive frames of
+// methods that were inlined into each other are folded into a single frame
+// of the method into which they were inlined.
+type TraceObfuscator struct {
+	// SourceFile The source file of the obfuscated frames, like
+	// "SourceFile", or empty to keep the original source files.
+	SourceFile string
+
+	*InverseFrameRemapper
+}
+
+func NewTraceObfuscator() *TraceObfuscator {
+	obfuscator := TraceObfuscator{
+		SourceFile:           "SourceFile",
+		InverseFrameRemapper: NewInverseFrameRemapper(),
+	}
+
+	return &obfuscator
+}
+
+// inlineGroups returns the inline groups whose innermost method is the
+// method of the given frame, in the order of the mapping file.
+func (obfuscator *TraceObfuscator) inlineGroups(frame *FrameInfo) []inlineGroup {
+	var groups []inlineGroup
+	for _, methodInfo := range obfuscator.ClassMethodMap[frame.ClassName][frame.MethodName] {
+		if methodInfo.HasInlinedMethods {
+			continue
+		}
+
+		group := inlineGroup{
+			obfuscatedClassName: methodInfo.ObfuscatedClassName,
+			obfuscatedName:      methodInfo.ObfuscatedName,
+		}
+		for method := methodInfo; method != nil; method = method.InlinedInto {
+			group.methods = append(group.methods, method.MethodInfo)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// ObfuscateFrames Returns the obfuscated frames of the given original
+// frames, innermost first. Consecutive frames that match an inline group
+// are folded into a single frame.
+func (obfuscator *TraceObfuscator) ObfuscateFrames(originalFrames []FrameInfo) []FrameInfo {
+	var obfuscatedFrames []FrameInfo
+	for index := 0; index < len(originalFrames); {
+		obfuscatedFrame, count := obfuscator.obfuscateFrame(originalFrames[index:])
+		obfuscatedFrames = append(obfuscatedFrames, obfuscatedFrame)
+		index += count
+	}
+
+	return obfuscatedFrames
+}
+
+// Obfuscate Reads a readable stack trace and writes the obfuscated stack
+// trace. Consecutive frames of inlined methods are folded, and the original
+// class names in other lines, like the exception names, are obfuscated.
+func (obfuscator *TraceObfuscator) Obfuscate(reader io.Reader, writer io.Writer) error {
+	pattern := NewFramePattern(REGULAR_EXPRESSION, false)
+
+	bufWriter := bufio.NewWriter(writer)
+
+	// The consecutive frame lines and their frames, which may be folded.
+	var lines []string
+	var frames []FrameInfo
+	flushFrames := func() {
+		for index := 0; index < len(frames); {
+			obfuscatedFrame, count := obfuscator.obfuscateFrame(frames[index:])
+			if obfuscatedFrame == frames[index] {
+				bufWriter.WriteString(lines[index])
+			} else {
+				bufWriter.WriteString(pattern.Format(lines[index], obfuscatedFrame))
+			}
+			index += count
+		}
+
+		lines = lines[:0]
+		frames = frames[:0]
+	}
+
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, err := bufReader.ReadString('\n')
+		if len(line) > 0 {
+			frame := pattern.Parse(line)
+			if len(frame.ClassName) > 0 && len(frame.MethodName) > 0 {
+				lines = append(lines, line)
+				frames = append(frames, frame)
+			} else {
+				flushFrames()
+				bufWriter.WriteString(obfuscator.obfuscateClassNames(line))
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	flushFrames()
+	return bufWriter.Flush()
+}
+
+// obfuscateFrame Returns the obfuscated frame of the first given frame, and
+// the number of frames that it folds. The frame is returned unchanged if
+// its class isn't in the mapping.
+func (obfuscator *TraceObfuscator) obfuscateFrame(frames []FrameInfo) (FrameInfo, int) {
+	frame := frames[0]
+
+	obfuscatedFrame := frame
+	if len(obfuscator.SourceFile) > 0 && frame.LineNumber > 0 {
+		obfuscatedFrame.SourceFile = obfuscator.SourceFile
+	}
+
+	// Prefer the longest inline group that matches, so that all inlined
+	// frames are folded.
+	groups := obfuscator.inlineGroups(&frame)
+	var bestGroup *inlineGroup
+	bestLineNumber := 0
+	for index := range groups {
+		group := &groups[index]
+		if bestGroup != nil && len(group.methods) <= len(bestGroup.methods) {
+			continue
+		}
+
+		if lineNumber, ok := group.obfuscatedLineNumber(frames); ok {
+			bestGroup = group
+			bestLineNumber = lineNumber
+		}
+	}
+
+	if bestGroup == nil {
+		// No line range matches, so just rename the method, if it exists
+		// by itself rather than only inlined into other methods.
+		for index := range groups {
+			outermost := &groups[index].methods[len(groups[index].methods)-1]
+			if outermost.OriginalClassName == frame.ClassName && outermost.OriginalName == frame.MethodName {
+				obfuscatedFrame.ClassName = groups[index].obfuscatedClassName
+				obfuscatedFrame.MethodName = groups[index].obfuscatedName
+				return obfuscatedFrame, 1
+			}
+		}
+
+		// The classes of inlined methods may not be in the mapping
+		// themselves.
+		obfuscatedClassName, ok := obfuscator.ClassMap[frame.ClassName]
+		if !ok {
+			return frame, 1
+		}
+
+		// The method wasn't renamed.
+		obfuscatedFrame.ClassName = obfuscatedClassName
+		return obfuscatedFrame, 1
+	}
+
+	obfuscatedFrame.ClassName = bestGroup.obfuscatedClassName
+	obfuscatedFrame.MethodName = bestGroup.obfuscatedName
+	if frame.LineNumber > 0 {
+		obfuscatedFrame.LineNumber = bestLineNumber
+	}
+
+	return obfuscatedFrame, len(bestGroup.methods)
+}
+
+// obfuscatedLineNumber returns the obfuscated line number at which the
+// methods of the group have the line numbers of the given frames, innermost
+// first, if the frames match the group.
+func (group *inlineGroup) obfuscatedLineNumber(frames []FrameInfo) (int, bool) {
+	if len(frames) < len(group.methods) {
+		return 0, false
+	}
+
+	// Find the obfuscated line number that the line number of any of the
+	// frames implies.
+	lineNumber := 0
+	found := false
+	for index := range group.methods {
+		methodInfo := &group.methods[index]
+		frame := &frames[index]
+		if methodInfo.OriginalClassName != frame.ClassName ||
+			methodInfo.OriginalName != frame.MethodName {
+			return 0, false
+		}
+
+		if found || frame.LineNumber <= 0 {
+			continue
+		}
+
+		if methodInfo.ObfuscatedLastLineNumber == 0 ||
+			methodInfo.OriginalFirstLineNumber == methodInfo.ObfuscatedFirstLineNumber {
+			// The line numbers weren't changed.
+			lineNumber = frame.LineNumber
+			found = true
+		} else if methodInfo.OriginalLastLineNumber != 0 &&
+			methodInfo.OriginalLastLineNumber != methodInfo.OriginalFirstLineNumber {
+			lineNumber = methodInfo.ObfuscatedFirstLineNumber + frame.LineNumber - methodInfo.OriginalFirstLineNumber
+			found = true
+		}
+	}
+
+	first := &group.methods[0]
+	if !found {
+		lineNumber = first.ObfuscatedFirstLineNumber
+	}
+
+	if first.ObfuscatedLastLineNumber != 0 &&
+		(lineNumber < first.ObfuscatedFirstLineNumber || first.ObfuscatedLastLineNumber < lineNumber) {
+		return 0, false
+	}
+
+	// Check that the obfuscated line number maps back to all frames.
+	for index := range group.methods {
+		if frames[index].LineNumber > 0 &&
+			group.methods[index].OriginalLineNumber(lineNumber) != frames[index].LineNumber {
+			return 0, false
+		}
+	}
+
+	return lineNumber, true
+}
+
+// obfuscateClassNames Returns the given line with all original class names
+// in it obfuscated, the reverse of Retrace.Deobfuscate.
+func (obfuscator *TraceObfuscator) obfuscateClassNames(line string) string {
+	var buffer strings.Builder
+	start := 0
+	for index, char := range line + " " {
+		if !deobfuscateFieldsFunc(char) {
+			continue
+		}
+
+		if token := line[start:index]; len(token) > 0 {
+			if obfuscatedClassName, ok := obfuscator.ClassMap[token]; ok {
+				token = obfuscatedClassName
+			}
+			buffer.WriteString(token)
+		}
+		if index < len(line) {
+			buffer.WriteRune(char)
+		}
+		start = index + len(string(char))
+	}
+
+	return buffer.String()
+}
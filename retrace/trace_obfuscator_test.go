@@ -0,0 +1,70 @@
+package retrace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const obfuscatorMapping = `com.example.Foo -> a:
+    1:3:void foo():10:12 -> a
+    4:4:void com.example.Bar.inlined():20:20 -> a
+    4:4:void foo():13:13 -> a
+    5:7:void foo():14:16 -> a
+    void bar(int) -> b
+com.example.FooException -> b:
+`
+
+const obfuscatorTrace = `com.example.FooException: boom
+	at com.example.Bar.inlined(Bar.java:20)
+	at com.example.Foo.foo(Foo.java:13)
+	at com.example.Foo.foo(Foo.java:15)
+	at com.example.Foo.bar(Foo.java:42)
+	at java.lang.Thread.run(Thread.java:764)
+`
+
+func TestTraceObfuscator(t *testing.T) {
+	obfuscator := NewTraceObfuscator()
+	assert.NoError(t, NewMappingReader(strings.NewReader(obfuscatorMapping)).Pump(obfuscator))
+
+	obfuscated := bytes.NewBufferString("")
+	assert.NoError(t, obfuscator.Obfuscate(strings.NewReader(obfuscatorTrace), obfuscated))
+	assert.Equal(t, `b: boom
+	at a.a(SourceFile:4)
+	at a.a(SourceFile:6)
+	at a.b(SourceFile:42)
+	at java.lang.Thread.run(Thread.java:764)
+`, obfuscated.String())
+
+	// Retracing the obfuscated trace results in the original trace.
+	retraced := bytes.NewBufferString("")
+	NewRetrace(strings.NewReader(obfuscatorMapping)).Retrace(strings.NewReader(obfuscated.String()), retraced)
+	assert.Equal(t, strings.Replace(obfuscatorTrace, "com.example.FooException", "b", 1), retraced.String())
+}
+
+func TestTraceObfuscatorFrames(t *testing.T) {
+	obfuscator := NewTraceObfuscator()
+	obfuscator.SourceFile = ""
+	assert.NoError(t, NewMappingReader(strings.NewReader(obfuscatorMapping)).Pump(obfuscator))
+
+	frames := obfuscator.ObfuscateFrames([]FrameInfo{
+		{ClassName: "com.example.Foo", SourceFile: "Foo.java", LineNumber: 13, MethodName: "foo"},
+		{ClassName: "com.example.Foo", SourceFile: "Foo.java", LineNumber: 99, MethodName: "foo"},
+		{ClassName: "com.example.Other", SourceFile: "Other.java", LineNumber: 1, MethodName: "foo"},
+		{ClassName: "com.example.Bar", SourceFile: "Bar.java", LineNumber: 99, MethodName: "inlined"},
+	})
+
+	assert.Equal(t, []FrameInfo{
+		// Only inlined code is at this line, so its line number can't be
+		// obfuscated without the inlined frame.
+		{ClassName: "a", SourceFile: "Foo.java", LineNumber: 13, MethodName: "a"},
+		// Outside of any line range.
+		{ClassName: "a", SourceFile: "Foo.java", LineNumber: 99, MethodName: "a"},
+		{ClassName: "com.example.Other", SourceFile: "Other.java", LineNumber: 1, MethodName: "foo"},
+		// The method only exists inlined into another class, so it isn't
+		// renamed to the method into which it was inlined.
+		{ClassName: "com.example.Bar", SourceFile: "Bar.java", LineNumber: 99, MethodName: "inlined"},
+	}, frames)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// smali Deobfuscates a smali file, or a directory of smali files as written
+// by baksmali, into a directory in which the files are named after the
+// original classes.
+func smali(args []string) {
+	flags := flag.NewFlagSet("smali", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	flags.Parse(args)
+	args = flags.Args()
+
+	if !selection.selected() && len(args) > 0 {
+		args = args[1:]
+	}
+	if len(args) < 1 || len(args) > 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	var mappingFilePath string
+	if selection.selected() {
+		mappingFilePath = selection.mappingFilePath()
+	} else {
+		mappingFilePath = flags.Arg(0)
+	}
+	inputPath := args[0]
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		fmt.Printf("Smali file or directory %s does not exist\n", inputPath)
+		os.Exit(1)
+	}
+
+	deobfuscator := retrace.NewSmaliDeobfuscator(readMapping(mappingFilePath))
+
+	// A single file is written to the standard output, unless an output
+	// directory is given.
+	if !info.IsDir() {
+		reader := openFile(inputPath, "Smali file")
+		defer reader.Close()
+
+		result := bytes.NewBufferString("")
+		originalClassName, err := deobfuscator.Deobfuscate(reader, result)
+		if err != nil {
+			fmt.Printf("Error deobfuscating smali file %s: %s\n", inputPath, err)
+			os.Exit(1)
+		}
+
+		if len(args) < 2 {
+			fmt.Printf("%s", result.String())
+		} else {
+			writeSmaliFile(args[1], "", originalClassName, deobfuscator.ObfuscatedClassName(), result.Bytes())
+		}
+		return
+	}
+
+	if len(args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+	outputPath := args[1]
+
+	count := 0
+	err = filepath.WalkDir(inputPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".smali") {
+			return err
+		}
+
+		result := bytes.NewBufferString("")
+		reader := openFile(path, "Smali file")
+		originalClassName, err := deobfuscator.Deobfuscate(reader, result)
+		reader.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping smali file %s: %s\n", path, err)
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(inputPath, path)
+		if err != nil {
+			return err
+		}
+
+		writeSmaliFile(outputPath, relativePath, originalClassName, deobfuscator.ObfuscatedClassName(), result.Bytes())
+		count++
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error reading smali directory %s: %s\n", inputPath, err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Deobfuscated %d smali files\n", count)
+}
+
+// writeSmaliFile Writes the given deobfuscated smali code into the given
+// output directory, in a file named after the original class. The file keeps
+// the directories that precede the obfuscated class in the given relative
+// path of the input file, like "smali_classes2".
+func writeSmaliFile(outputPath string, relativePath string, originalClassName string, obfuscatedClassName string, code []byte) {
+	prefix := ""
+	obfuscatedPath := filepath.FromSlash(retrace.InternalClassName(obfuscatedClassName) + ".smali")
+	if strings.HasSuffix(relativePath, obfuscatedPath) {
+		prefix = strings.TrimSuffix(relativePath, obfuscatedPath)
+	}
+
+	filePath := filepath.Join(outputPath, prefix, filepath.FromSlash(retrace.InternalClassName(originalClassName)+".smali"))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		fmt.Printf("Error creating directory for %s: %s\n", filePath, err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(filePath, code, 0644); err != nil {
+		fmt.Printf("Error writing smali file %s: %s\n", filePath, err)
+		os.Exit(1)
+	}
+}
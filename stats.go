@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// stats Audits how much of the code in a mapping keeps its original names.
+func stats(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print the statistics as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	mappingStats := retrace.ComputeMappingStats(readMapping(flags.Arg(0)))
+
+	var err error
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(mappingStats)
+	} else {
+		err = mappingStats.WriteSummary(os.Stdout)
+	}
+
+	if err != nil {
+		fmt.Printf("Error writing statistics: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swind/go-retrace/retrace"
+)
+
+// storeFlags The flags that select mappings in a mapping store.
+type storeFlags struct {
+	root    *string
+	app     *string
+	version *string
+	flavor  *string
+	mapID   *string
+}
+
+// addStoreFlags Adds the flags that select mappings in a mapping store to the
+// given flag set.
+func addStoreFlags(flags *flag.FlagSet) *storeFlags {
+	return &storeFlags{
+		root:    flags.String("store", defaultStoreRoot(), "the directory of the mapping store, or $GO_RETRACE_STORE"),
+		app:     flags.String("app", "", "the app ID of the mapping, like com.foo"),
+		version: flags.String("version", "", "the version name or version code of the mapping"),
+		flavor:  flags.String("flavor", "", "the build flavor of the mapping"),
+		mapID:   flags.String("map-id", "", "the pg_map_id or debug ID of the mapping"),
+	}
+}
+
+// selected returns whether the flags select a mapping in the store.
+func (s *storeFlags) selected() bool {
+	return len(*s.app) > 0 || len(*s.mapID) > 0
+}
+
+func (s *storeFlags) query() retrace.MappingQuery {
+	return retrace.MappingQuery{
+		AppID:   *s.app,
+		Version: *s.version,
+		Flavor:  *s.flavor,
+		MapID:   *s.mapID,
+	}
+}
+
+// open Opens the selected mapping store, or exits if it can't.
+func (s *storeFlags) open() *retrace.MappingStore {
+	store, err := retrace.OpenMappingStore(*s.root)
+	if err != nil {
+		fmt.Printf("Error opening mapping store %s: %s\n", *s.root, err)
+		os.Exit(1)
+	}
+
+	return store
+}
+
+// mappingFilePath returns the path of the latest mapping that the flags
+// select, or exits if there is none.
+func (s *storeFlags) mappingFilePath() string {
+	store := s.open()
+	entry, err := store.Get(s.query())
+	if errors.Is(err, retrace.ErrMappingNotFound) {
+		fmt.Printf("No mapping found in %s for %s\n", *s.root, describeQuery(s.query()))
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error reading mapping store %s: %s\n", *s.root, err)
+		os.Exit(1)
+	}
+
+	return store.ObjectPath(entry)
+}
+
+// defaultStoreRoot returns $GO_RETRACE_STORE, or ~/.go-retrace/mappings.
+func defaultStoreRoot() string {
+	if root := os.Getenv("GO_RETRACE_STORE"); len(root) > 0 {
+		return root
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".go-retrace"
+	}
+
+	return filepath.Join(home, ".go-retrace", "mappings")
+}
+
+func describeQuery(query retrace.MappingQuery) string {
+	var parts []string
+	for _, part := range [][2]string{
+		{"app", query.AppID},
+		{"version", query.Version},
+		{"flavor", query.Flavor},
+		{"map ID", query.MapID},
+	} {
+		if len(part[1]) > 0 {
+			parts = append(parts, part[0]+" "+part[1])
+		}
+	}
+
+	if len(parts) == 0 {
+		return "any app"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// store Registers, lists, finds and removes mappings in a mapping store.
+func store(args []string) {
+	if len(args) < 1 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		storeAdd(args[1:])
+	case "list":
+		storeList(args[1:])
+	case "get":
+		storeGet(args[1:])
+	case "delete":
+		storeDelete(args[1:])
+	case "prune":
+		storePrune(args[1:])
+	default:
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+// storeAdd Registers a mapping file in the store.
+func storeAdd(args []string) {
+	flags := flag.NewFlagSet("store add", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	versionCode := flags.Int("version-code", 0, "the version code of the mapping, if -version is the version name")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || len(*selection.app) == 0 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	entry := retrace.MappingStoreEntry{
+		AppID:       *selection.app,
+		VersionName: *selection.version,
+		VersionCode: *versionCode,
+		Flavor:      *selection.flavor,
+		MapID:       *selection.mapID,
+	}
+
+	// A numeric version is the version code.
+	if code, err := strconv.Atoi(entry.VersionName); err == nil && entry.VersionCode == 0 {
+		entry.VersionName = ""
+		entry.VersionCode = code
+	}
+
+	mappingFilePath := flags.Arg(0)
+	reader := openFile(mappingFilePath, "Mapping file")
+	defer reader.Close()
+
+	added, err := selection.open().Add(entry, reader)
+	if err != nil {
+		fmt.Printf("Error adding mapping file %s: %s\n", mappingFilePath, err)
+		os.Exit(1)
+	}
+
+	printStoreEntry(added)
+}
+
+// storeList Prints the mappings in the store, optionally as JSON.
+func storeList(args []string) {
+	flags := flag.NewFlagSet("store list", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	jsonOutput := flags.Bool("json", false, "print the mappings as JSON")
+	flags.Parse(args)
+
+	entries, err := selection.open().List(selection.query())
+	if err != nil {
+		fmt.Printf("Error reading mapping store %s: %s\n", *selection.root, err)
+		os.Exit(1)
+	}
+
+	printStoreEntries(entries, *jsonOutput)
+}
+
+// storeGet Prints the path of the latest mapping file that matches the
+// flags, so that other commands can read it.
+func storeGet(args []string) {
+	flags := flag.NewFlagSet("store get", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	flags.Parse(args)
+
+	fmt.Println(selection.mappingFilePath())
+}
+
+// storeDelete Removes the mappings that match the flags.
+func storeDelete(args []string) {
+	flags := flag.NewFlagSet("store delete", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	flags.Parse(args)
+
+	// Don't remove the entire store by accident.
+	if !selection.selected() {
+		printUsage()
+		os.Exit(1)
+	}
+
+	removed, err := selection.open().Delete(selection.query())
+	if err != nil {
+		fmt.Printf("Error deleting mappings: %s\n", err)
+		os.Exit(1)
+	}
+
+	printStoreEntries(removed, false)
+}
+
+// storePrune Removes the mappings that match the flags and that the
+// retention policy doesn't keep.
+func storePrune(args []string) {
+	flags := flag.NewFlagSet("store prune", flag.ExitOnError)
+	selection := addStoreFlags(flags)
+	keep := flags.Int("keep", 0, "the number of latest versions to keep of every app and flavor")
+	maxAge := flags.String("max-age", "", "the age after which mappings are removed, like 90d or 720h")
+	flags.Parse(args)
+
+	policy := retrace.RetentionPolicy{KeepLatest: *keep}
+	if len(*maxAge) > 0 {
+		age, err := parseAge(*maxAge)
+		if err != nil {
+			fmt.Printf("Invalid maximum age %s: %s\n", *maxAge, err)
+			os.Exit(1)
+		}
+		policy.MaxAge = age
+	}
+
+	if policy.KeepLatest <= 0 && policy.MaxAge <= 0 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	removed, err := selection.open().Prune(selection.query(), policy)
+	if err != nil {
+		fmt.Printf("Error pruning mappings: %s\n", err)
+		os.Exit(1)
+	}
+
+	printStoreEntries(removed, false)
+}
+
+// parseAge Parses a duration like "720h", or a number of days like "90d".
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		count, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(age)
+}
+
+func printStoreEntries(entries []retrace.MappingStoreEntry, jsonOutput bool) {
+	if jsonOutput {
+		if entries == nil {
+			entries = []retrace.MappingStoreEntry{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			fmt.Printf("Error writing mappings: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	for index := range entries {
+		printStoreEntry(&entries[index])
+	}
+}
+
+func printStoreEntry(entry *retrace.MappingStoreEntry) {
+	flavor := entry.Flavor
+	if len(flavor) == 0 {
+		flavor = "-"
+	}
+	mapID := entry.MapID
+	if len(mapID) == 0 {
+		mapID = "-"
+	}
+
+	fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n",
+		entry.AppID,
+		entry.Version(),
+		flavor,
+		mapID,
+		entry.DebugID,
+		entry.AddedAt.Format(time.RFC3339))
+}